@@ -4,7 +4,6 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 
 	"grafana2signoz/internal/mapper"
@@ -36,17 +35,20 @@ func main() {
 		fatal(err)
 	}
 
-	// Build widget index by numeric ID (strip leading 'w_')
+	// Build widget index by the Grafana panel id each widget came from
 	wid := map[int]mapper.SigNozWidget{}
 	for _, w := range sd.Widgets {
-		id := strings.TrimPrefix(w.ID, "w_")
-		if n, err := strconv.Atoi(id); err == nil {
+		if n, ok := mapper.WidgetPanelID(w); ok {
 			wid[n] = w
 		}
 	}
 
 	mismatches := 0
 	for _, p := range gd.Panels {
+		// Text panels moved into the dashboard description have no widget.
+		if strings.EqualFold(p.Type, "text") && rules.TextPanels == mapper.TextDescription {
+			continue
+		}
 		w, ok := wid[p.ID]
 		if !ok {
 			fmt.Printf("missing: grafana panel id %d title=%q type=%q not found in SigNoz widgets\n", p.ID, p.Title, p.Type)
@@ -54,11 +56,7 @@ func main() {
 			continue
 		}
 		// Expected mapped type
-		pt := strings.ToLower(p.Type)
-		expect := rules.PanelTypeMap[pt]
-		if expect == "" {
-			expect = rules.DefaultPanel
-		}
+		expect := mapper.PanelTypeFor(p, rules)
 		if w.PanelType != expect {
 			fmt.Printf("type mismatch: id=%d title=%q grafana=%q expected_signoz=%q got=%q\n", p.ID, p.Title, p.Type, expect, w.PanelType)
 			mismatches++
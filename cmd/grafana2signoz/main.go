@@ -17,6 +17,12 @@ var (
 	inputPath  string
 	outputPath string
 	rulesPath  string
+	reportPath string
+	repeatMode string
+	idMode     string
+	textPanels string
+	libPath    string
+	dsTypes    map[string]string
 	dryRun     bool
 )
 
@@ -38,26 +44,67 @@ func main() {
 			if err != nil {
 				return err
 			}
+			switch repeatMode {
+			case "":
+			case mapper.RepeatGroup, mapper.RepeatExpand:
+				rules.RepeatMode = repeatMode
+			default:
+				return fmt.Errorf("--repeat-mode must be %q or %q", mapper.RepeatGroup, mapper.RepeatExpand)
+			}
+			switch idMode {
+			case "":
+			case mapper.IDRandom, mapper.IDDeterministic:
+				rules.IDMode = idMode
+			default:
+				return fmt.Errorf("--id-mode must be %q or %q", mapper.IDRandom, mapper.IDDeterministic)
+			}
+			switch textPanels {
+			case "":
+			case mapper.TextWidget, mapper.TextDescription:
+				rules.TextPanels = textPanels
+			default:
+				return fmt.Errorf("--text-panels must be %q or %q", mapper.TextWidget, mapper.TextDescription)
+			}
+			for ref, typ := range dsTypes {
+				if rules.Datasources == nil {
+					rules.Datasources = map[string]string{}
+				}
+				rules.Datasources[ref] = typ
+			}
+
+			var lib parser.LibraryPanels
+			if libPath != "" {
+				if lib, err = parser.LoadLibraryPanels(libPath); err != nil {
+					return err
+				}
+			}
 
 			info, err := os.Stat(inputPath)
 			if err != nil {
 				return err
 			}
 			if info.IsDir() {
-				return convertDir(inputPath, outputPath, rules)
+				return convertDir(inputPath, outputPath, reportPath, rules, lib)
 			}
 
 			// Single file
-			gDash, err := parser.ParseGrafanaDashboardFile(inputPath)
+			gDash, missing, err := parseDashboard(inputPath, lib)
 			if err != nil {
 				return err
 			}
-			sDash := mapper.GrafanaToSigNoz(gDash, rules)
+			sDash, report := mapper.Convert(gDash, rules)
+			report.AddMissingLibraryPanels(missing)
 			if errs := output.ValidateSigNozDashboard(sDash); len(errs) > 0 {
 				for _, e := range errs {
 					fmt.Fprintf(os.Stderr, "validation: %v\n", e)
 				}
 			}
+			output.PrintReport(os.Stderr, report)
+			if reportPath != "" {
+				if err := writeReportFile(reportPath, report); err != nil {
+					return err
+				}
+			}
 			if dryRun {
 				return output.WriteSigNozDashboard(os.Stdout, sDash)
 			}
@@ -75,6 +122,12 @@ func main() {
 	convertCmd.Flags().StringVar(&inputPath, "input", "", "Path to Grafana dashboard JSON")
 	convertCmd.Flags().StringVar(&outputPath, "output", "", "Path to write SigNoz JSON")
 	convertCmd.Flags().StringVar(&rulesPath, "rules", "", "Optional path to custom mapping rules JSON")
+	convertCmd.Flags().StringVar(&reportPath, "report", "", "Optional path to write the conversion report JSON (a directory when --input is a directory)")
+	convertCmd.Flags().StringVar(&repeatMode, "repeat-mode", "", "How to convert repeating panels/rows: group (one widget grouped by the variable) or expand (one widget per value); overrides rules")
+	convertCmd.Flags().StringVar(&idMode, "id-mode", "", "How to generate widget/variable ids: random or deterministic (derived from the dashboard uid, panel id and refId); overrides rules")
+	convertCmd.Flags().StringVar(&textPanels, "text-panels", "", "How to convert text panels: description (default; content moved into the dashboard description) or widget (content as widget description); overrides rules")
+	convertCmd.Flags().StringVar(&libPath, "library-panels", "", "Optional library panel export (JSON file or directory) used to inline panels referenced by libraryPanel")
+	convertCmd.Flags().StringToStringVar(&dsTypes, "datasource", nil, "Datasource type for a reference, e.g. DS_PROMETHEUS=prometheus (repeatable); overrides rules")
 	convertCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print SigNoz JSON to stdout without writing a file")
 
 	validateCmd := &cobra.Command{
@@ -136,7 +189,7 @@ func main() {
 	}
 }
 
-func convertDir(inDir, outPath string, rules *mapper.Rules) error {
+func convertDir(inDir, outPath, reportDir string, rules *mapper.Rules, lib parser.LibraryPanels) error {
 	// Determine output directory: if --output is file or dir
 	outDir := outPath
 	if outDir == "" {
@@ -145,12 +198,23 @@ func convertDir(inDir, outPath string, rules *mapper.Rules) error {
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
 		return err
 	}
+	if reportDir != "" {
+		if err := os.MkdirAll(reportDir, 0o755); err != nil {
+			return err
+		}
+	}
 
 	entries, err := os.ReadDir(inDir)
 	if err != nil {
 		return err
 	}
 	var lastErr error
+	type dashboard struct {
+		name    string
+		dash    *parser.GrafanaDashboard
+		missing []parser.GrafanaLibraryPanelRef
+	}
+	var dashboards []dashboard
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
@@ -159,19 +223,45 @@ func convertDir(inDir, outPath string, rules *mapper.Rules) error {
 			continue
 		}
 		inFile := filepath.Join(inDir, e.Name())
-		gDash, err := parser.ParseGrafanaDashboardFile(inFile)
+		gDash, missing, err := parseDashboard(inFile, lib)
 		if err != nil {
 			lastErr = err
 			fmt.Fprintf(os.Stderr, "skip %s: %v\n", e.Name(), err)
 			continue
 		}
-		sDash := mapper.GrafanaToSigNoz(gDash, rules)
+		dashboards = append(dashboards, dashboard{e.Name(), gDash, missing})
+	}
+
+	// Give every dashboard of the folder its SigNoz id up front, so links
+	// between them point at the converted dashboards.
+	folderRules := *rules
+	folderRules.DashboardIDs = map[string]string{}
+	for _, d := range dashboards {
+		if d.dash.UID != "" {
+			folderRules.DashboardIDs[d.dash.UID] = mapper.DashboardID(d.dash, rules.IDMode)
+		}
+	}
+	for uid, id := range rules.DashboardIDs {
+		folderRules.DashboardIDs[uid] = id
+	}
+
+	for _, d := range dashboards {
+		name, gDash := d.name, d.dash
+		sDash, report := mapper.Convert(gDash, &folderRules)
+		report.AddMissingLibraryPanels(d.missing)
 		if errs := output.ValidateSigNozDashboard(sDash); len(errs) > 0 {
 			for _, ve := range errs {
-				fmt.Fprintf(os.Stderr, "%s: validation: %v\n", e.Name(), ve)
+				fmt.Fprintf(os.Stderr, "%s: validation: %v\n", name, ve)
 			}
 		}
-		outFile := filepath.Join(outDir, fmt.Sprintf("converted-%s", e.Name()))
+		output.PrintReport(os.Stderr, report)
+		if reportDir != "" {
+			if err := writeReportFile(filepath.Join(reportDir, fmt.Sprintf("report-%s", name)), report); err != nil {
+				lastErr = err
+				fmt.Fprintf(os.Stderr, "write report %s: %v\n", name, err)
+			}
+		}
+		outFile := filepath.Join(outDir, fmt.Sprintf("converted-%s", name))
 		f, err := os.Create(outFile)
 		if err != nil {
 			lastErr = err
@@ -185,3 +275,23 @@ func convertDir(inDir, outPath string, rules *mapper.Rules) error {
 	}
 	return lastErr
 }
+
+// parseDashboard parses a Grafana dashboard and inlines the library panels
+// it references. It returns the references that could not be resolved,
+// which belong in the conversion report.
+func parseDashboard(path string, lib parser.LibraryPanels) (*parser.GrafanaDashboard, []parser.GrafanaLibraryPanelRef, error) {
+	gDash, err := parser.ParseGrafanaDashboardFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gDash, parser.ResolveLibraryPanels(gDash, lib), nil
+}
+
+func writeReportFile(path string, report *mapper.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return output.WriteReport(f, report)
+}
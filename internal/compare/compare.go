@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"grafana2signoz/internal/mapper"
@@ -29,17 +28,20 @@ func CompareDashboards(w io.Writer, grafanaPath, signozPath string, rules *mappe
 		rules = &r
 	}
 
-	// Build widget index by numeric panel id from "w_<id>"
+	// Build widget index by the Grafana panel id each widget came from
 	wid := map[int]mapper.SigNozWidget{}
 	for _, wdg := range sd.Widgets {
-		id := strings.TrimPrefix(wdg.ID, "w_")
-		if n, err := strconv.Atoi(id); err == nil {
+		if n, ok := mapper.WidgetPanelID(wdg); ok {
 			wid[n] = wdg
 		}
 	}
 
 	mismatches := 0
 	for _, p := range gd.Panels {
+		// Text panels moved into the dashboard description have no widget.
+		if strings.EqualFold(p.Type, "text") && rules.TextPanels == mapper.TextDescription {
+			continue
+		}
 		wdg, ok := wid[p.ID]
 		if !ok {
 			fmt.Fprintf(w, "missing: grafana panel id %d title=%q type=%q not found in SigNoz widgets\n", p.ID, p.Title, p.Type)
@@ -47,11 +49,7 @@ func CompareDashboards(w io.Writer, grafanaPath, signozPath string, rules *mappe
 			continue
 		}
 		// expected mapped panel type
-		pt := strings.ToLower(p.Type)
-		expect := rules.PanelTypeMap[pt]
-		if expect == "" {
-			expect = rules.DefaultPanel
-		}
+		expect := mapper.PanelTypeFor(p, rules)
 		if wdg.PanelType != expect {
 			fmt.Fprintf(w, "type mismatch: id=%d title=%q grafana=%q expected_signoz=%q got=%q\n", p.ID, p.Title, p.Type, expect, wdg.PanelType)
 			mismatches++
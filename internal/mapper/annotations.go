@@ -0,0 +1,77 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// Annotation modes for Rules.Annotations.
+const (
+	// AnnotationsWidget converts Prometheus annotations into event widgets
+	// and reports the others.
+	AnnotationsWidget = "widget"
+	// AnnotationsReport only lists annotations in the conversion report.
+	AnnotationsReport = "report"
+)
+
+// annotationPanels returns a bar panel per enabled Prometheus annotation,
+// plotting the annotation expression as events over time, and reports every
+// annotation SigNoz has no counterpart for, and disabled ones. The built-in
+// "Annotations & Alerts" annotation is skipped. Datasources are classified
+// as for targets. Panels get negative ids, which do not clash with
+// Grafana's.
+func annotationPanels(g *parser.GrafanaDashboard, rules *Rules, ds *datasources, r *Report) []parser.GrafanaPanel {
+	var out []parser.GrafanaPanel
+	for i, a := range g.Annotations.List {
+		if a.BuiltIn == 1 {
+			continue
+		}
+		name := nonEmpty(a.Name, fmt.Sprintf("annotation %d", i+1))
+		expr, typ, tags := a.Expr, ds.typeOf(a.Datasource), a.Tags
+		if t := a.Target; t != nil {
+			expr = nonEmpty(expr, t.Expr)
+			if len(tags) == 0 {
+				tags = t.Tags
+			}
+			if a.Type == "" {
+				a.Type = t.Type
+			}
+		}
+		if !a.Enable {
+			r.add("", "", fmt.Sprintf("annotation %q is disabled in Grafana; not converted", name))
+			continue
+		}
+		switch {
+		case typ == dsGrafana || typ == dsDashboard:
+			if a.Type == "tags" {
+				r.add("", "", fmt.Sprintf("annotation %q shows Grafana annotations tagged %s; not converted", name, strings.Join(tags, ", ")))
+			} else {
+				r.add("", "", fmt.Sprintf("annotation %q shows the dashboard's Grafana annotations; not converted", name))
+			}
+		case expr == "":
+			r.add("", "", fmt.Sprintf("annotation %q (%s) has no query; not converted", name, nonEmpty(typ, "default datasource")))
+		case typ == dsUnknown:
+			r.add("", "", fmt.Sprintf("annotation %q: datasource could not be resolved; query %q is not converted", name, expr))
+		case typ != "" && typ != dsPrometheus:
+			r.add("", "", fmt.Sprintf("annotation %q: %s query %q is not converted", name, typ, expr))
+		case rules.Annotations == AnnotationsReport:
+			r.add("", "", fmt.Sprintf("annotation %q: Prometheus query %q is not converted", name, expr))
+		default:
+			r.add("", "", fmt.Sprintf("annotation %q is converted into an event widget; SigNoz cannot overlay it on other widgets (an alert on %q can notify on it)", name, expr))
+			out = append(out, parser.GrafanaPanel{
+				ID:    -(i + 1),
+				Type:  "barchart",
+				Title: "Annotation: " + name,
+				Targets: []parser.GrafanaTarget{{
+					RefID:        "A",
+					Expr:         expr,
+					LegendFormat: a.TitleFormat,
+					Interval:     a.Step,
+				}},
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"grafana2signoz/internal/parser"
+)
+
+// Untranslated keeps the Grafana panel and target fields the converter does
+// not translate, so they can be audited after import. Targets are keyed by
+// refId.
+type Untranslated struct {
+	Panel   json.RawMessage            `json:"panel,omitempty"`
+	Targets map[string]json.RawMessage `json:"targets,omitempty"`
+}
+
+// untranslated collects the fields of p and its targets that have no SigNoz
+// counterpart, or returns nil when there are none.
+func untranslated(p parser.GrafanaPanel) *Untranslated {
+	u := &Untranslated{Panel: p.Raw}
+	for i, t := range p.Targets {
+		fields := targetAuditFields(t)
+		if len(fields) == 0 {
+			continue
+		}
+		b, err := json.Marshal(fields)
+		if err != nil {
+			continue
+		}
+		if u.Targets == nil {
+			u.Targets = map[string]json.RawMessage{}
+		}
+		u.Targets[nonEmpty(t.RefID, fmt.Sprintf("#%d", i))] = b
+	}
+	if len(u.Panel) == 0 && len(u.Targets) == 0 {
+		return nil
+	}
+	return u
+}
+
+// targetAuditFields returns the unmodelled fields of t plus the modelled
+// query options that are not translated.
+func targetAuditFields(t parser.GrafanaTarget) map[string]interface{} {
+	out := map[string]interface{}{}
+	if len(t.Raw) > 0 {
+		_ = json.Unmarshal(t.Raw, &out)
+	}
+	set := func(key string, v interface{}, ok bool) {
+		if ok {
+			out[key] = v
+		}
+	}
+	set("interval", t.Interval, t.Interval != "" && durationSeconds(t.Interval) == 0)
+	set("intervalFactor", t.IntervalFactor, t.IntervalFactor > 1)
+	set("exemplar", t.Exemplar, t.Exemplar)
+	set("rawSql", t.RawSQL, t.RawSQL != "")
+	set("query", t.Query, t.Query != nil && t.Query != "")
+	set("metrics", t.Metrics, len(t.Metrics) > 0)
+	return out
+}
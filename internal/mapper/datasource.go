@@ -0,0 +1,164 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// Datasource types (Grafana plugin ids) the converter distinguishes.
+const (
+	dsPrometheus = "prometheus"
+	dsMixed      = "mixed"
+	dsGrafana    = "grafana"
+	dsDashboard  = "dashboard"
+	// dsUnknown is the type of datasource names and uids that cannot be
+	// resolved. Their queries are not converted.
+	dsUnknown = "unknown"
+)
+
+// pluginTypes are datasource plugin ids that plain datasource names often
+// equal, e.g. "Loki" or "InfluxDB" in pre-v8 dashboards.
+var pluginTypes = map[string]bool{
+	"prometheus": true, "loki": true, "influxdb": true, "elasticsearch": true,
+	"graphite": true, "mysql": true, "postgres": true, "mssql": true,
+	"cloudwatch": true, "stackdriver": true, "opentsdb": true, "tempo": true,
+	"jaeger": true, "zipkin": true, "testdata": true,
+}
+
+// placeholderRegexp matches ${NAME}, $NAME and [[NAME]] datasource references.
+var placeholderRegexp = regexp.MustCompile(`^(?:\$\{([^}:]+)(?::[^}]*)?\}|\$(\w+)|\[\[(\w+)\]\])$`)
+
+// datasources classifies panel and target datasource references by type.
+// Placeholders are resolved from "__inputs" (${DS_PROMETHEUS}), datasource
+// variables ($datasource) and Rules.Datasources, which takes precedence and
+// may also name datasources or uids directly.
+type datasources struct {
+	types map[string]string
+	// unresolved collects placeholders without a known type.
+	unresolved map[string]bool
+	// unknown collects datasource names and uids without a known type.
+	unknown map[string]bool
+}
+
+func newDatasources(g *parser.GrafanaDashboard, rules *Rules) *datasources {
+	d := &datasources{types: map[string]string{}, unresolved: map[string]bool{}, unknown: map[string]bool{}}
+	for _, in := range g.Inputs {
+		if in.Type == "datasource" && in.PluginID != "" {
+			d.types[in.Name] = strings.ToLower(in.PluginID)
+		}
+	}
+	for _, v := range g.Templating.List {
+		if v.Type != "datasource" {
+			continue
+		}
+		if q, ok := v.Query.(string); ok && q != "" {
+			d.types[v.Name] = strings.ToLower(q)
+		}
+	}
+	for ref, typ := range rules.Datasources {
+		d.types[ref] = strings.ToLower(typ)
+	}
+	return d
+}
+
+// typeOf returns the datasource type of a panel or target "datasource"
+// value: a name or placeholder string, or a {type, uid} object. It returns
+// "" when the reference is empty or an unresolved placeholder, and
+// dsUnknown for names and uids that cannot be resolved.
+func (d *datasources) typeOf(ref interface{}) string {
+	switch v := ref.(type) {
+	case string:
+		return d.resolve(v)
+	case map[string]interface{}:
+		typ, _ := v["type"].(string)
+		uid, _ := v["uid"].(string)
+		// Built-in datasources have type "datasource" and are named by uid.
+		if typ != "" && typ != "datasource" && !strings.HasPrefix(typ, "$") {
+			return strings.ToLower(typ)
+		}
+		if uid != "" {
+			return d.resolve(uid)
+		}
+		return d.resolve(typ)
+	}
+	return ""
+}
+
+func (d *datasources) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	switch ref {
+	case "":
+		return ""
+	case "-- Mixed --":
+		return dsMixed
+	case "-- Grafana --", "grafana":
+		return dsGrafana
+	case "-- Dashboard --":
+		return dsDashboard
+	}
+	if t, ok := d.types[ref]; ok {
+		return t
+	}
+	if m := placeholderRegexp.FindStringSubmatch(ref); m != nil {
+		name := m[1] + m[2] + m[3]
+		if t, ok := d.types[name]; ok {
+			return t
+		}
+		d.unresolved[name] = true
+		return ""
+	}
+	// Plain datasource names often are the type ("Prometheus", "Loki").
+	if typ := strings.ToLower(ref); pluginTypes[typ] {
+		return typ
+	}
+	d.unknown[ref] = true
+	return dsUnknown
+}
+
+// targetType is the datasource type of t in panel p. Targets without their
+// own datasource use the panel's. Targets without any datasource use the
+// default one, and unresolved placeholders, which are assumed to be
+// Prometheus (what the converter translates).
+func (d *datasources) targetType(p parser.GrafanaPanel, t parser.GrafanaTarget) string {
+	typ := d.typeOf(t.Datasource)
+	if typ == "" || typ == dsMixed {
+		if pt := d.typeOf(p.Datasource); pt != dsMixed {
+			typ = pt
+		}
+	}
+	if typ == "" {
+		return dsPrometheus
+	}
+	return typ
+}
+
+// promTargets returns the Prometheus targets of p. Other targets cannot be
+// translated into SigNoz metrics queries and are returned as warnings.
+func (d *datasources) promTargets(p parser.GrafanaPanel) ([]parser.GrafanaTarget, []string) {
+	var out []parser.GrafanaTarget
+	var warns []string
+	for _, t := range p.Targets {
+		switch typ := d.targetType(p, t); typ {
+		case dsPrometheus:
+			out = append(out, t)
+		case dsUnknown:
+			warns = append(warns, fmt.Sprintf("target %s: datasource could not be resolved; query not converted", nonEmpty(t.RefID, "?")))
+		default:
+			warns = append(warns, fmt.Sprintf("target %s: %s datasource queries are not converted", nonEmpty(t.RefID, "?"), typ))
+		}
+	}
+	return out, warns
+}
+
+// report lists the placeholders, names and uids that could not be resolved.
+func (d *datasources) report(r *Report) {
+	for _, n := range sortedKeys(d.unknown) {
+		r.add("", "", fmt.Sprintf("datasource %q could not be resolved; its queries are not converted; map it with rules.datasources or --datasource '%s=<type>'", n, n))
+	}
+	for _, n := range sortedKeys(d.unresolved) {
+		r.add("", "", fmt.Sprintf("datasource $%s could not be resolved and is assumed to be Prometheus; map it with rules.datasources or --datasource %s=<type>", n, n))
+	}
+}
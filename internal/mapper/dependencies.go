@@ -0,0 +1,100 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// variableRefsRegexp matches any variable reference: $name, ${name},
+// ${name:format} and [[name]].
+var variableRefsRegexp = regexp.MustCompile(`\$\{([\w.]+)(?::[^}]*)?\}|\[\[([\w.]+)(?::[^\]]*)?\]\]|\$(\w+)`)
+
+// variableRefs returns the variables referenced in s, in order of first
+// use. Grafana's global variables ($__interval, $__all, ...) are skipped.
+func variableRefs(s string) []string {
+	var out []string
+	for _, m := range variableRefsRegexp.FindAllStringSubmatch(s, -1) {
+		name := m[1] + m[2] + m[3]
+		if !strings.HasPrefix(name, "__") && !contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// variableOrder returns the list indexes of the variables SigNoz gets, in
+// dependency order: a variable comes after the variables its query and
+// regex reference, and otherwise keeps its Grafana position. Constant and
+// interval references are inlined before the references are collected.
+// Cycles and references to variables that are dropped are reported.
+func variableOrder(list []parser.GrafanaVariable, inlined map[string]string, r *Report) []int {
+	index := map[string]int{}
+	for i, v := range list {
+		if _, ok := index[v.Name]; !ok && v.Name != "" {
+			index[v.Name] = i
+		}
+	}
+	emitted := func(v parser.GrafanaVariable) bool {
+		_, ok := variableTypes[strings.ToLower(v.Type)]
+		return ok
+	}
+	deps := make([][]int, len(list))
+	for i, v := range list {
+		if !emitted(v) {
+			continue
+		}
+		for _, name := range variableRefs(inlineText(variableQuery(v)+" "+v.Regex, inlined)) {
+			j, ok := index[name]
+			switch {
+			case !ok || j == i:
+			case !emitted(list[j]):
+				r.add("", "", fmt.Sprintf("variable $%s references $%s (%s variable), which is not converted", v.Name, name, nonEmpty(strings.ToLower(list[j].Type), "query")))
+			default:
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	// Depth-first search in list order; a variable is emitted once all its
+	// dependencies are. A dependency still on the stack closes a cycle.
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(list))
+	var order, stack []int
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, j := range deps[i] {
+			switch state[j] {
+			case unvisited:
+				visit(j)
+			case visiting:
+				var names []string
+				for k := len(stack) - 1; k >= 0; k-- {
+					names = append([]string{"$" + list[stack[k]].Name}, names...)
+					if stack[k] == j {
+						break
+					}
+				}
+				names = append(names, "$"+list[j].Name)
+				r.add("", "", fmt.Sprintf("variables form a dependency cycle (%s); SigNoz cannot resolve them", strings.Join(names, " → ")))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+		order = append(order, i)
+	}
+	for i, v := range list {
+		if emitted(v) && state[i] == unvisited {
+			visit(i)
+		}
+	}
+	return order
+}
@@ -0,0 +1,72 @@
+package mapper
+
+import (
+	"encoding/json"
+
+	"grafana2signoz/internal/parser"
+)
+
+// fieldConfig is the subset of a Grafana panel's fieldConfig read by the mapper.
+type fieldConfig struct {
+	Defaults  fieldDefaults   `json:"defaults"`
+	Overrides []fieldOverride `json:"overrides"`
+}
+
+// fieldOverride applies properties to the fields selected by its matcher.
+type fieldOverride struct {
+	Matcher struct {
+		ID      string      `json:"id"` // byName, byRegexp, byFrameRefID, byType
+		Options interface{} `json:"options"`
+	} `json:"matcher"`
+	Properties []struct {
+		ID    string          `json:"id"` // unit, displayName, color, custom.hideFrom
+		Value json.RawMessage `json:"value"`
+	} `json:"properties"`
+}
+
+type fieldDefaults struct {
+	Unit     string         `json:"unit"`
+	Custom   fieldCustom    `json:"custom"`
+	Mappings []valueMapping `json:"mappings"`
+	// Links are data links, opened from a data point.
+	Links []parser.GrafanaLink `json:"links"`
+}
+
+type fieldCustom struct {
+	DrawStyle   string   `json:"drawStyle"` // line, bars, points
+	FillOpacity *float64 `json:"fillOpacity"`
+	// SpanNulls is either a bool or a gap threshold in milliseconds.
+	SpanNulls interface{} `json:"spanNulls"`
+	Stacking  struct {
+		Mode string `json:"mode"` // none, normal, percent
+	} `json:"stacking"`
+}
+
+// decodeFieldConfig decodes a panel's fieldConfig; malformed or missing
+// configs yield the zero value.
+func decodeFieldConfig(raw json.RawMessage) fieldConfig {
+	var fc fieldConfig
+	if len(raw) == 0 {
+		return fc
+	}
+	_ = json.Unmarshal(raw, &fc)
+	return fc
+}
+
+// valueMapping is a Grafana value mapping. Grafana 8+ uses a string type
+// (value, range, regex, special) with type-specific options; Grafana 7 used a
+// numeric type (1 = value, 2 = range) with flat fields.
+type valueMapping struct {
+	Type    interface{}     `json:"type"`
+	Options json.RawMessage `json:"options"`
+	// Grafana 7 fields
+	Value string `json:"value"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Text  string `json:"text"`
+}
+
+type mappingResult struct {
+	Text  string `json:"text"`
+	Color string `json:"color"`
+}
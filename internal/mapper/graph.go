@@ -0,0 +1,88 @@
+package mapper
+
+import (
+	"strconv"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// PanelTypeFor returns the SigNoz panel type for a Grafana panel. The lookup
+// uses rules.PanelTypeMap; graph panels drawn as bars become bar panels and
+// rows stay rows.
+func PanelTypeFor(p parser.GrafanaPanel, rules *Rules) string {
+	pt := strings.ToLower(p.Type)
+	if pt == "row" {
+		return "row"
+	}
+	mapped, ok := rules.PanelTypeMap[pt]
+	if !ok || mapped == "" {
+		mapped = rules.DefaultPanel
+	}
+	if mapped == "graph" && drawsBars(p, decodeFieldConfig(p.FieldCfg)) {
+		return "bar"
+	}
+	return mapped
+}
+
+// drawsBars reports whether a timeseries (drawStyle) or legacy graph panel
+// (bars without lines) renders its series as bars.
+func drawsBars(p parser.GrafanaPanel, fc fieldConfig) bool {
+	if strings.EqualFold(fc.Defaults.Custom.DrawStyle, "bars") {
+		return true
+	}
+	return p.Bars && p.Lines != nil && !*p.Lines
+}
+
+// applyGraphOptions maps Grafana stacking, fill and null handling onto the
+// widget's isStacked, opacity, fillSpans and nullZeroValues settings.
+func applyGraphOptions(w *SigNozWidget, p parser.GrafanaPanel) {
+	fc := decodeFieldConfig(p.FieldCfg)
+	custom := fc.Defaults.Custom
+
+	switch strings.ToLower(custom.Stacking.Mode) {
+	case "normal", "percent":
+		w.IsStacked = true
+	}
+	if p.Stack {
+		w.IsStacked = true
+	}
+	if w.PanelType == "bar" {
+		w.StackedBarChart = w.IsStacked
+	}
+
+	// A fill of 0 (Grafana's default) disables the area fill; SigNoz's
+	// opacity applies to the series themselves, so it keeps the default.
+	w.Opacity = "1"
+	switch {
+	case custom.FillOpacity != nil && *custom.FillOpacity > 0:
+		// timeseries: 0-100
+		w.Opacity = formatOpacity(*custom.FillOpacity / 100)
+	case custom.FillOpacity == nil && p.Fill != nil && *p.Fill > 0:
+		// legacy graph: 0-10
+		w.Opacity = formatOpacity(float64(*p.Fill) / 10)
+	}
+
+	switch v := custom.SpanNulls.(type) {
+	case bool:
+		w.FillSpans = v
+	case float64:
+		w.FillSpans = v > 0
+	}
+	switch strings.ToLower(p.NullPointMode) {
+	case "connected":
+		w.FillSpans = true
+	case "null as zero":
+		w.NullZeroValues = "zero"
+	}
+}
+
+func formatOpacity(v float64) string {
+	if v < 0 {
+		v = 0
+	}
+	if v > 1 {
+		v = 1
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
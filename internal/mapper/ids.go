@@ -0,0 +1,143 @@
+package mapper
+
+import (
+	crand "crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// ID modes for Rules.IDMode.
+const (
+	// IDRandom gives every conversion fresh random (v4) UUIDs.
+	IDRandom = "random"
+	// IDDeterministic derives name-based (v5) UUIDs from the dashboard uid,
+	// panel ids and refIds, so converting the same dashboard twice gives
+	// byte-identical output.
+	IDDeterministic = "deterministic"
+)
+
+// idNamespace is the UUID v5 namespace of converted dashboards.
+var idNamespace = uuidV5([16]byte{}, "grafana2signoz")
+
+// ids generates the widget, query, variable, threshold and link IDs of one
+// dashboard conversion.
+type ids struct {
+	deterministic bool
+	ns            [16]byte
+	// seen counts the panels named so far per Grafana panel id.
+	seen map[int]int
+}
+
+func newIDs(g *parser.GrafanaDashboard, mode string) *ids {
+	return &ids{
+		deterministic: mode == IDDeterministic,
+		ns:            uuidV5(idNamespace, nonEmpty(g.UID, g.Title)),
+		seen:          map[int]int{},
+	}
+}
+
+// id returns the ID named by parts, e.g. ("widget", 4) or
+// ("filter", 4, "A", 0): random, or derived from the dashboard and parts.
+func (g *ids) id(parts ...interface{}) string {
+	if !g.deterministic {
+		return newUUID()
+	}
+	names := make([]string, len(parts))
+	for i, p := range parts {
+		names[i] = fmt.Sprint(p)
+	}
+	return formatUUID(uuidV5(g.ns, strings.Join(names, "/")))
+}
+
+// panelKey names panel p in the IDs of its widget. Panels without an id,
+// or sharing one, are told apart by their position among those panels, so
+// every panel must be named once, in dashboard order.
+func (g *ids) panelKey(p parser.GrafanaPanel) string {
+	g.seen[p.ID]++
+	if n := g.seen[p.ID]; n > 1 {
+		return fmt.Sprintf("%d#%d", p.ID, n)
+	}
+	return fmt.Sprint(p.ID)
+}
+
+// widgetID is the ID of the widget converted from the panel named key.
+func (g *ids) widgetID(key string) string {
+	return g.id("widget", key)
+}
+
+// assignWidgetIDs sets the query, filter, threshold and link IDs of the
+// widget converted from the panel named key.
+func (g *ids) assignWidgetIDs(w *SigNozWidget, key string) {
+	w.Query.ID = g.id("query", key)
+	for i := range w.Query.Builder.QueryData {
+		q := &w.Query.Builder.QueryData[i]
+		for j := range q.Filters.Items {
+			// SigNoz uses short ids for filter items.
+			q.Filters.Items[j].ID = g.id("filter", key, q.QueryName, j)[:8]
+		}
+	}
+	for i := range w.Thresholds {
+		w.Thresholds[i].Index = g.id("threshold", key, i)
+	}
+	for i := range w.ContextLinks.LinksData {
+		w.ContextLinks.LinksData[i].ID = g.id("link", key, i)
+	}
+}
+
+// DashboardID returns the id of the SigNoz dashboard converted from g: a
+// random UUID, or in deterministic mode one derived from its uid.
+func DashboardID(g *parser.GrafanaDashboard, mode string) string {
+	return newIDs(g, mode).id("dashboard")
+}
+
+// newUUID returns a pseudo-random UUID v4 string.
+func newUUID() string {
+	var b [16]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		// very unlikely; fallback to zeros which still produce a string
+	}
+	// Set version and variant bits
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return formatUUID(b)
+}
+
+// uuidV5 returns the name-based (SHA-1) UUID of name in namespace ns.
+func uuidV5(ns [16]byte, name string) [16]byte {
+	h := sha1.New()
+	h.Write(ns[:])
+	h.Write([]byte(name))
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	b[6] = (b[6] & 0x0f) | 0x50
+	b[8] = (b[8] & 0x3f) | 0x80
+	return b
+}
+
+func formatUUID(b [16]byte) string {
+	const hexdigits = "0123456789abcdef"
+	sb := strings.Builder{}
+	for i, v := range b {
+		sb.WriteByte(hexdigits[v>>4])
+		sb.WriteByte(hexdigits[v&0x0f])
+		switch i {
+		case 3, 5, 7, 9:
+			sb.WriteByte('-')
+		}
+	}
+	return sb.String()
+}
+
+// WidgetPanelID returns the id of the Grafana panel a widget was converted
+// from. Widgets written before UUID ids carry it in their "w_<id>" id.
+func WidgetPanelID(w SigNozWidget) (int, bool) {
+	if w.GrafanaPanelID != 0 {
+		return w.GrafanaPanelID, true
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(w.ID, "w_"))
+	return n, err == nil && strings.HasPrefix(w.ID, "w_")
+}
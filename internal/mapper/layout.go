@@ -0,0 +1,228 @@
+package mapper
+
+import (
+	"math"
+	"sort"
+
+	"grafana2signoz/internal/parser"
+)
+
+// Grid widths of Grafana (gridPos) and SigNoz (layout) dashboards.
+const (
+	grafanaCols = 24
+	signozCols  = 12
+)
+
+// layoutItem is a widget layout and whether it came from a Grafana gridPos.
+type layoutItem struct {
+	l          SigNozLayout
+	positioned bool
+	row        bool
+}
+
+// layoutEngine translates Grafana gridPos coordinates onto SigNoz's grid.
+// Columns are scaled from 24 to 12, x/y positions are kept, and collisions
+// caused by rounding are resolved by moving widgets down in visual order.
+// Panels without a gridPos are packed below the positioned ones.
+type layoutEngine struct {
+	defW, defH int
+	main       []layoutItem
+	rows       []string
+	collapsed  map[string]bool
+	members    map[string][]layoutItem
+}
+
+func newLayoutEngine(rules *Rules) *layoutEngine {
+	return &layoutEngine{
+		defW:      rules.DefaultWidth,
+		defH:      rules.DefaultHeight,
+		collapsed: map[string]bool{},
+		members:   map[string][]layoutItem{},
+	}
+}
+
+// addRow adds a row widget for the Grafana row panel p.
+func (e *layoutEngine) addRow(id string, p parser.GrafanaPanel) {
+	it := layoutItem{l: SigNozLayout{H: 1, W: signozCols, I: id, MaxH: 1, MinH: 1, MinW: signozCols}, row: true}
+	if p.GridPos != nil {
+		it.l.Y = p.GridPos.Y
+		it.positioned = true
+	}
+	e.main = append(e.main, it)
+	e.rows = append(e.rows, id)
+	e.collapsed[id] = p.Collapsed
+}
+
+// add adds the widget for panel p, inside row rowID when non-empty.
+func (e *layoutEngine) add(id string, p parser.GrafanaPanel, rowID string) {
+	it := layoutItem{l: SigNozLayout{W: e.defW, H: e.defH, I: id}}
+	if g := p.GridPos; g != nil {
+		x, end := scaleCol(g.X), scaleCol(g.X+g.W)
+		it.l.X = x
+		if g.W > 0 {
+			it.l.W = end - x
+		}
+		if g.H > 0 {
+			it.l.H = g.H
+		}
+		it.l.Y = g.Y
+		it.positioned = true
+	}
+	clampWidth(&it.l)
+	if rowID != "" && e.collapsed[rowID] {
+		e.members[rowID] = append(e.members[rowID], it)
+		return
+	}
+	e.main = append(e.main, it)
+	if rowID != "" {
+		e.members[rowID] = append(e.members[rowID], it)
+	}
+}
+
+// build returns the dashboard layout and the panelMap of row members.
+func (e *layoutEngine) build() ([]SigNozLayout, map[string]PanelMapEntry) {
+	main := place(e.main, 0)
+	byID := map[string]SigNozLayout{}
+	for _, l := range main {
+		byID[l.I] = l
+	}
+
+	panelMap := map[string]PanelMapEntry{}
+	for _, rowID := range e.rows {
+		entry := PanelMapEntry{Collapsed: e.collapsed[rowID], Widgets: []SigNozLayout{}}
+		if entry.Collapsed {
+			// Collapsed members keep their offsets relative to the first
+			// member and open directly below the row.
+			members := e.members[rowID]
+			minY := math.MaxInt
+			for _, it := range members {
+				if it.positioned && it.l.Y < minY {
+					minY = it.l.Y
+				}
+			}
+			base := byID[rowID].Y + 1
+			for i := range members {
+				if members[i].positioned {
+					members[i].l.Y = base + members[i].l.Y - minY
+				}
+			}
+			entry.Widgets = place(members, base)
+		} else {
+			for _, it := range e.members[rowID] {
+				entry.Widgets = append(entry.Widgets, byID[it.l.I])
+			}
+		}
+		panelMap[rowID] = entry
+	}
+	return main, panelMap
+}
+
+// place packs unpositioned items below the positioned ones (starting no
+// higher than minY) and resolves collisions. Layouts keep the items' order.
+func place(items []layoutItem, minY int) []SigNozLayout {
+	bottom := minY
+	for _, it := range items {
+		if it.positioned && it.l.Y+it.l.H > bottom {
+			bottom = it.l.Y + it.l.H
+		}
+	}
+	pk := &packer{cols: signozCols, y: bottom}
+	out := make([]SigNozLayout, len(items))
+	for i, it := range items {
+		switch {
+		case it.positioned:
+			out[i] = it.l
+		case it.row:
+			out[i] = pk.placeRow(it.l)
+		default:
+			out[i] = pk.place(it.l)
+		}
+	}
+	resolveCollisions(out)
+	return out
+}
+
+// resolveCollisions moves overlapping layouts down. Layouts are visited
+// top-to-bottom, left-to-right and only ever move down, so the visual order
+// is preserved.
+func resolveCollisions(ls []SigNozLayout) {
+	idx := make([]int, len(ls))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		la, lb := ls[idx[a]], ls[idx[b]]
+		if la.Y == lb.Y {
+			return la.X < lb.X
+		}
+		return la.Y < lb.Y
+	})
+	for n, i := range idx {
+		for moved := true; moved; {
+			moved = false
+			for _, j := range idx[:n] {
+				if overlaps(ls[i], ls[j]) {
+					ls[i].Y = ls[j].Y + ls[j].H
+					moved = true
+				}
+			}
+		}
+	}
+}
+
+func overlaps(a, b SigNozLayout) bool {
+	return a.X < b.X+b.W && b.X < a.X+a.W && a.Y < b.Y+b.H && b.Y < a.Y+a.H
+}
+
+// scaleCol maps a Grafana column edge onto the SigNoz grid. Scaling edges
+// rather than widths keeps adjacent panels adjacent.
+func scaleCol(c int) int {
+	return int(math.Round(float64(c) * signozCols / grafanaCols))
+}
+
+func clampWidth(l *SigNozLayout) {
+	if l.W < 1 {
+		l.W = 1
+	}
+	if l.W > signozCols {
+		l.W = signozCols
+	}
+	if l.X+l.W > signozCols {
+		l.X = signozCols - l.W
+	}
+}
+
+// packer places widgets left to right, wrapping to a new line when a widget
+// does not fit.
+type packer struct {
+	cols, x, y, lineH int
+}
+
+func (pk *packer) place(l SigNozLayout) SigNozLayout {
+	if pk.x+l.W > pk.cols { // wrap to next row
+		pk.newline()
+	}
+	l.X, l.Y = pk.x, pk.y
+	pk.x += l.W
+	if l.H > pk.lineH {
+		pk.lineH = l.H
+	}
+	return l
+}
+
+func (pk *packer) newline() {
+	if pk.x == 0 && pk.lineH == 0 {
+		return
+	}
+	pk.x = 0
+	pk.y += pk.lineH
+	pk.lineH = 0
+}
+
+// placeRow places a full-width row on its own line.
+func (pk *packer) placeRow(l SigNozLayout) SigNozLayout {
+	pk.newline()
+	l.X, l.Y = 0, pk.y
+	pk.y += l.H
+	return l
+}
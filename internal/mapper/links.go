@@ -0,0 +1,138 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// ContextLinks is a widget's list of drill-down links.
+type ContextLinks struct {
+	LinksData []ContextLink `json:"linksData"`
+}
+
+// ContextLink is a SigNoz context link. The URL may use {{name}} for
+// dashboard variables and series labels, and {{_timestamp_start}} /
+// {{_timestamp_end}} for the time range (epoch milliseconds).
+type ContextLink struct {
+	ID    string `json:"id"`
+	URL   string `json:"url"`
+	Label string `json:"label"`
+}
+
+var (
+	// linkVariableRegexp matches ${name[:format]}, [[name]] and $name.
+	linkVariableRegexp = regexp.MustCompile(`\$\{([^}]+)\}|\[\[([^\]]+)\]\]|\$(\w+)`)
+	// dashboardURLRegexp matches links to Grafana dashboards, /d/<uid>/<slug>,
+	// with or without the Grafana host and sub path.
+	dashboardURLRegexp = regexp.MustCompile(`^(?:https?://[^/?#]+)?(?:/[^/?#]+)*?/d/([\w-]+)(?:/[^/?#]*)?`)
+)
+
+// links converts dashboard links, panel links and data links into widget
+// context links.
+type links struct {
+	// dashboard holds the converted URL-type dashboard links, which every
+	// widget gets after its own links.
+	dashboard  []ContextLink
+	dashboards map[string]string
+}
+
+// newLinks converts the dashboard's links. Dashboard links listing
+// dashboards by tag and URLs that cannot be rewritten are reported.
+func newLinks(g *parser.GrafanaDashboard, rules *Rules, r *Report) *links {
+	l := &links{dashboards: rules.DashboardIDs}
+	for _, gl := range g.Links {
+		if gl.Type == "dashboards" {
+			r.add("", "", fmt.Sprintf("dashboard link %q lists the dashboards tagged %s; SigNoz has no dashboard links", nonEmpty(gl.Title, "Dashboards"), strings.Join(gl.Tags, ", ")))
+			continue
+		}
+		cl, warns := l.convert(gl)
+		for _, w := range warns {
+			r.add("", "", w)
+		}
+		l.dashboard = append(l.dashboard, cl)
+	}
+	return l
+}
+
+// applyLinks sets a widget's context links from the panel's links, the
+// data links in fieldConfig.defaults.links and the dashboard links.
+// Link ids are set by ids.assignWidgetIDs.
+func (l *links) applyLinks(w *SigNozWidget, p parser.GrafanaPanel) {
+	all := append(append([]parser.GrafanaLink(nil), p.Links...), decodeFieldConfig(p.FieldCfg).Defaults.Links...)
+	for _, gl := range all {
+		cl, warns := l.convert(gl)
+		w.ContextLinks.LinksData = append(w.ContextLinks.LinksData, cl)
+		w.Warnings = append(w.Warnings, warns...)
+	}
+	w.ContextLinks.LinksData = append(w.ContextLinks.LinksData, l.dashboard...)
+}
+
+// convert rewrites a Grafana link into a context link: links to converted
+// dashboards point at their SigNoz dashboard, and Grafana's URL variables
+// become their SigNoz equivalents.
+func (l *links) convert(gl parser.GrafanaLink) (ContextLink, []string) {
+	label := nonEmpty(gl.Title, gl.URL)
+	var warns []string
+	u := gl.URL
+	if m := dashboardURLRegexp.FindStringSubmatchIndex(u); m != nil {
+		uid := u[m[2]:m[3]]
+		if id, ok := l.dashboards[uid]; ok {
+			u = "/dashboard/" + id + u[m[1]:]
+		} else {
+			warns = append(warns, fmt.Sprintf("link %q points to Grafana dashboard %s, which has no converted id", label, uid))
+		}
+	}
+	if gl.KeepTime {
+		u = appendQuery(u, "${__url_time_range}")
+	}
+	if gl.IncludeVars {
+		u = appendQuery(u, "${__all_variables}")
+	}
+	u = linkVariableRegexp.ReplaceAllStringFunc(u, func(ref string) string {
+		m := linkVariableRegexp.FindStringSubmatch(ref)
+		name, _, _ := strings.Cut(m[1]+m[2]+m[3], ":")
+		if out, ok := linkVariable(name); ok {
+			return out
+		}
+		warns = append(warns, fmt.Sprintf("link %q: %s has no SigNoz equivalent", label, ref))
+		if name == "__all_variables" {
+			return ""
+		}
+		return ref
+	})
+	return ContextLink{URL: strings.TrimRight(u, "?&"), Label: label}, warns
+}
+
+// linkVariable returns the SigNoz counterpart of a Grafana link variable.
+func linkVariable(name string) (string, bool) {
+	switch {
+	case name == "__url_time_range":
+		return "startTime={{_timestamp_start}}&endTime={{_timestamp_end}}", true
+	case name == "__from":
+		return "{{_timestamp_start}}", true
+	case name == "__to":
+		return "{{_timestamp_end}}", true
+	case strings.HasPrefix(name, "__field.labels."):
+		return "{{" + strings.TrimPrefix(name, "__field.labels.") + "}}", true
+	case strings.HasPrefix(name, "__data.fields.") || strings.HasPrefix(name, "__data.fields["):
+		field := strings.Trim(strings.TrimPrefix(name, "__data.fields"), `.[]"`)
+		return "{{" + field + "}}", true
+	case strings.HasPrefix(name, "__"):
+		return "", false
+	}
+	return "{{" + name + "}}", true
+}
+
+// appendQuery appends a query parameter string to u.
+func appendQuery(u, param string) string {
+	switch {
+	case !strings.Contains(u, "?"):
+		return u + "?" + param
+	case strings.HasSuffix(u, "?"), strings.HasSuffix(u, "&"):
+		return u + param
+	}
+	return u + "&" + param
+}
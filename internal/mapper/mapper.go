@@ -1,13 +1,11 @@
 package mapper
 
 import (
-	crand "crypto/rand"
 	"encoding/json"
 	"fmt"
 	"math"
 	"os"
 	"regexp"
-	"sort"
 	"strings"
 
 	"grafana2signoz/internal/parser"
@@ -20,9 +18,32 @@ type Rules struct {
 	DefaultPanel string            `json:"defaultPanel"`
 	// QueryReplacements are applied to target expressions in order.
 	QueryReplacements []Replacement `json:"queryReplacements"`
-	// Default grid sizes for widgets (SigNoz uses 24 cols). Defaults 6x6.
+	// Default grid sizes for widgets without a Grafana gridPos (SigNoz uses
+	// 12 cols). Defaults 6x6.
 	DefaultWidth  int `json:"defaultWidth"`
 	DefaultHeight int `json:"defaultHeight"`
+	// RepeatMode is how repeating panels and rows are converted: "group"
+	// (default) or "expand".
+	RepeatMode string `json:"repeatMode"`
+	// Datasources maps datasource references to their type (plugin id, e.g.
+	// "prometheus"): "__inputs" names such as DS_PROMETHEUS, datasource
+	// variable names, datasource names or uids. Only Prometheus targets are
+	// converted into queries.
+	Datasources map[string]string `json:"datasources"`
+	// IDMode is how widget, query and variable IDs are generated: "random"
+	// (default) or "deterministic".
+	IDMode string `json:"idMode"`
+	// DashboardIDs maps Grafana dashboard uids to the ids of their converted
+	// SigNoz dashboards. Links to these dashboards are rewritten, and a
+	// dashboard listed here gets its id as uuid.
+	DashboardIDs map[string]string `json:"dashboardIds"`
+	// Annotations is how annotation queries are converted: "widget"
+	// (default) or "report".
+	Annotations string `json:"annotations"`
+	// TextPanels is how text panels are converted: "description" (default)
+	// moves their content into the dashboard description, "widget" keeps it
+	// as the description of a widget without queries.
+	TextPanels string `json:"textPanels"`
 }
 
 type Replacement struct {
@@ -58,6 +79,18 @@ func LoadRules(path string) (*Rules, error) {
 	if r.DefaultHeight == 0 {
 		r.DefaultHeight = def.DefaultHeight
 	}
+	if r.RepeatMode == "" {
+		r.RepeatMode = def.RepeatMode
+	}
+	if r.IDMode == "" {
+		r.IDMode = def.IDMode
+	}
+	if r.Annotations == "" {
+		r.Annotations = def.Annotations
+	}
+	if r.TextPanels == "" {
+		r.TextPanels = def.TextPanels
+	}
 	return &r, nil
 }
 
@@ -84,21 +117,27 @@ func DefaultRules() Rules {
 		DefaultPanel:  "graph",
 		DefaultWidth:  6,
 		DefaultHeight: 6,
+		RepeatMode:    RepeatGroup,
+		IDMode:        IDRandom,
+		Annotations:   AnnotationsWidget,
+		TextPanels:    TextDescription,
 	}
 }
 
 // Internal normalized SigNoz structures (subset needed for import)
 type SigNozDashboard struct {
-	Title           string                 `json:"title"`
-	UUID            string                 `json:"uuid,omitempty"`
-	Version         string                 `json:"version"`
-	Tags            []string               `json:"tags"`
-	Layout          []SigNozLayout         `json:"layout"`
-	Widgets         []SigNozWidget         `json:"widgets"`
-	Variables       map[string]interface{} `json:"variables"`
-	PanelMap        map[string]interface{} `json:"panelMap,omitempty"`
-	UploadedGrafana bool                   `json:"uploadedGrafana,omitempty"`
-	Description     string                 `json:"description,omitempty"`
+	Title           string                   `json:"title"`
+	UUID            string                   `json:"uuid,omitempty"`
+	Version         string                   `json:"version"`
+	Tags            []string                 `json:"tags"`
+	Layout          []SigNozLayout           `json:"layout"`
+	Widgets         []SigNozWidget           `json:"widgets"`
+	Variables       map[string]interface{}   `json:"variables"`
+	PanelMap        map[string]PanelMapEntry `json:"panelMap,omitempty"`
+	UploadedGrafana bool                     `json:"uploadedGrafana,omitempty"`
+	Description     string                   `json:"description,omitempty"`
+	// Grafana records the source dashboard's identity and folder.
+	Grafana *GrafanaSource `json:"_grafana,omitempty"`
 }
 
 type SigNozLayout struct {
@@ -109,20 +148,58 @@ type SigNozLayout struct {
 	I      string `json:"i"`
 	Moved  bool   `json:"moved"`
 	Static bool   `json:"static"`
+	// Size constraints, set on row widgets
+	MaxH int `json:"maxH,omitempty"`
+	MinH int `json:"minH,omitempty"`
+	MinW int `json:"minW,omitempty"`
+}
+
+// PanelMapEntry records the members of a row widget and whether the row is
+// collapsed. Members of collapsed rows are not part of the dashboard layout.
+type PanelMapEntry struct {
+	Collapsed bool           `json:"collapsed"`
+	Widgets   []SigNozLayout `json:"widgets"`
 }
 
 type SigNozWidget struct {
-	ID             string                 `json:"id"`
-	Title          string                 `json:"title"`
-	PanelType      string                 `json:"panelTypes"`
-	TimePreference string                 `json:"timePreferance"`
-	Description    string                 `json:"description,omitempty"`
-	Query          map[string]interface{} `json:"query"`
+	ID             string `json:"id"`
+	Title          string `json:"title"`
+	PanelType      string `json:"panelTypes"`
+	TimePreference string `json:"timePreferance"`
+	Description    string `json:"description,omitempty"`
+	Query          Query  `json:"query"`
+	// Graph display options
+	IsStacked       bool   `json:"isStacked"`
+	StackedBarChart bool   `json:"stackedBarChart"`
+	Opacity         string `json:"opacity,omitempty"`
+	FillSpans       bool   `json:"fillSpans"`
+	NullZeroValues  string `json:"nullZeroValues,omitempty"`
+	// Units and series colors
+	YAxisUnit          string            `json:"yAxisUnit,omitempty"`
+	ColumnUnits        map[string]string `json:"columnUnits"`
+	CustomLegendColors map[string]string `json:"customLegendColors,omitempty"`
+	// Labelled thresholds carry value mappings
+	Thresholds []Threshold `json:"thresholds"`
+	// Panel links, data links and dashboard links
+	ContextLinks ContextLinks `json:"contextLinks"`
+	// Warnings lists Grafana settings that could not be converted.
+	Warnings []string `json:"_conversionWarnings,omitempty"`
+	// GrafanaPanelID is the id of the panel the widget was converted from.
+	GrafanaPanelID int `json:"_grafanaPanelId,omitempty"`
+	// Untranslated keeps Grafana fields without a SigNoz counterpart.
+	Untranslated *Untranslated `json:"_grafanaUntranslated,omitempty"`
 }
 
 // GrafanaToSigNoz converts a parsed Grafana dashboard to a SigNoz dashboard
 // using provided rules.
 func GrafanaToSigNoz(g *parser.GrafanaDashboard, rules *Rules) SigNozDashboard {
+	s, _ := Convert(g, rules)
+	return s
+}
+
+// Convert is GrafanaToSigNoz that also returns a report of the settings
+// which could not be translated.
+func Convert(g *parser.GrafanaDashboard, rules *Rules) (SigNozDashboard, *Report) {
 	if rules == nil {
 		r := DefaultRules()
 		rules = &r
@@ -131,89 +208,117 @@ func GrafanaToSigNoz(g *parser.GrafanaDashboard, rules *Rules) SigNozDashboard {
 	s := SigNozDashboard{
 		Title:           nonEmpty(g.Title, "Migrated Grafana Dashboard"),
 		Version:         "v4",
-		Tags:            []string{"migrated", "grafana"},
+		Tags:            dashboardTags(g),
 		Layout:          []SigNozLayout{},
 		Widgets:         []SigNozWidget{},
 		Variables:       map[string]interface{}{},
-		PanelMap:        map[string]interface{}{},
+		PanelMap:        map[string]PanelMapEntry{},
 		UploadedGrafana: false,
-		Description:     "Converted from Grafana dashboard JSON",
+		Description:     nonEmpty(g.Description, "Converted from Grafana dashboard JSON"),
+		Grafana:         grafanaSource(g),
+	}
+	if g.UID != "" {
+		s.UUID = rules.DashboardIDs[g.UID]
 	}
 
-	// Variables mapping (best effort)
-	s.Variables = buildVariables(g)
-
-	// Panels -> Widgets with simple grid packing (24 cols)
-	const cols = 24
-	curX, curY := 0, 0
-	rowH := 0
-
-	// Sort panels by grid position when available to keep visual order.
-	panels := append([]parser.GrafanaPanel(nil), g.Panels...)
-	sort.SliceStable(panels, func(i, j int) bool {
-		pi, pj := panels[i].GridPos, panels[j].GridPos
-		if pi == nil && pj == nil {
-			return panels[i].ID < panels[j].ID
-		}
-		if pi == nil {
-			return false
-		}
-		if pj == nil {
-			return true
-		}
-		if pi.Y == pj.Y {
-			return pi.X < pj.X
-		}
-		return pi.Y < pj.Y
-	})
-
-	for _, p := range panels {
-		pt := strings.ToLower(p.Type)
-		mapped, ok := rules.PanelTypeMap[pt]
-		if !ok || mapped == "" {
-			mapped = rules.DefaultPanel
-		}
-
-		// Compose a basic widget query: preserve original targets as a note
-		q := makeSigNozQueryFromTargets(p.Targets, rules)
-
-		id := fmt.Sprintf("w_%d", p.ID)
-		widget := SigNozWidget{
-			ID:             id,
-			Title:          nonEmpty(p.Title, strings.Title(mapped)),
-			PanelType:      mapped,
-			TimePreference: "GLOBAL_TIME",
-			Description:    fmt.Sprintf("Migrated from Grafana (type: %s); original queries preserved in _grafanaExprs.", pt),
-			Query:          q,
-		}
+	report := &Report{Dashboard: s.Title}
+	reportRequires(g, rules, report)
+	reportTimeSettings(g, report)
+	ds := newDatasources(g, rules)
+	ids := newIDs(g, rules.IDMode)
+	lk := newLinks(g, rules, report)
 
-		w := rules.DefaultWidth
-		h := rules.DefaultHeight
-		if p.GridPos != nil {
-			if p.GridPos.W > 0 {
-				w = p.GridPos.W
-			}
-			if p.GridPos.H > 0 {
-				h = p.GridPos.H
+	// Variables mapping (best effort)
+	s.Variables = buildVariables(g, ds, ids, report)
+
+	// Panels -> Widgets, one section per Grafana row so that rows keep
+	// their members. Layout is translated onto SigNoz's 12-column grid.
+	panels, warns := expandRepeats(g, rules.RepeatMode)
+	panels = inlineVariables(g, panels)
+	for _, w := range warns {
+		report.add("", "", w)
+	}
+	if rules.TextPanels == TextDescription {
+		if secs := textSections(panels); len(secs) > 0 {
+			s.Description = strings.Join(append([]string{s.Description}, secs...), "\n\n")
+		}
+	}
+	lay := newLayoutEngine(rules)
+	for _, sec := range rowSections(panels) {
+		rowID := ""
+		if sec.row != nil {
+			rw := rowWidget(*sec.row, ids)
+			rowID = rw.ID
+			s.Widgets = append(s.Widgets, rw)
+			lay.addRow(rw.ID, *sec.row)
+		}
+		for _, p := range sec.panels {
+			if isTextPanel(p) && rules.TextPanels == TextDescription {
+				continue
 			}
+			widget := buildWidget(p, rules, ds, lk, ids)
+			s.Widgets = append(s.Widgets, widget)
+			lay.add(widget.ID, p, rowID)
 		}
-
-		if curX+w > cols { // wrap to next row
-			curX = 0
-			curY += rowH
-			rowH = 0
-		}
-		layout := SigNozLayout{H: h, W: w, X: curX, Y: curY, I: id, Moved: false, Static: false}
-		curX += w
-		if h > rowH {
-			rowH = h
-		}
-
-		s.Widgets = append(s.Widgets, widget)
-		s.Layout = append(s.Layout, layout)
 	}
-
-	return s
+	// Annotations are not tied to panels; their widgets go below the others.
+	for _, p := range inlineVariables(g, annotationPanels(g, rules, ds, report)) {
+		widget := buildWidget(p, rules, ds, lk, ids)
+		widget.Description = "Converted from a Grafana annotation query; events are plotted as bars."
+		widget.GrafanaPanelID = 0
+		s.Widgets = append(s.Widgets, widget)
+		lay.add(widget.ID, p, "")
+	}
+	s.Layout, s.PanelMap = lay.build()
+	ds.report(report)
+
+	report.addWidgetWarnings(s.Widgets)
+	return s, report
+}
+
+// buildWidget converts a single non-row Grafana panel into a SigNoz widget.
+func buildWidget(p parser.GrafanaPanel, rules *Rules, ds *datasources, lk *links, ids *ids) SigNozWidget {
+	pt := strings.ToLower(p.Type)
+	mapped := PanelTypeFor(p, rules)
+	key := ids.panelKey(p)
+
+	// Compose a basic widget query from the Prometheus targets; all original
+	// expressions are preserved as a note.
+	targets, dsWarns := ds.promTargets(p)
+	q := makeSigNozQueryFromTargets(targets)
+	q.GrafanaExprs = collectExprs(p.Targets, rules.QueryReplacements)
+
+	widget := SigNozWidget{
+		ID:             ids.widgetID(key),
+		Title:          nonEmpty(p.Title, strings.Title(mapped)),
+		PanelType:      mapped,
+		TimePreference: "GLOBAL_TIME",
+		Description:    fmt.Sprintf("Migrated from Grafana (type: %s); original queries preserved in _grafanaExprs.", pt),
+		Query:          q,
+		ColumnUnits:    map[string]string{},
+		Thresholds:     []Threshold{},
+		ContextLinks:   ContextLinks{LinksData: []ContextLink{}},
+		Warnings:       dsWarns,
+		Untranslated:   untranslated(p),
+	}
+	if lp := p.LibraryPanel; lp != nil {
+		// Unresolved reference: the panel has no model to convert.
+		widget.Title = nonEmpty(p.Title, nonEmpty(lp.Name, widget.Title))
+		widget.Warnings = append(widget.Warnings, missingLibraryPanel(*lp))
+	}
+	applyGraphOptions(&widget, p)
+	applyReduceTo(&widget, p)
+	applyInstantTargets(&widget, p)
+	applyValueMappings(&widget, p)
+	applyOverrides(&widget, p)
+	applyTableOptions(&widget, p)
+	applyRepeatGroup(&widget, p)
+	applyTimeOverrides(&widget, p)
+	applyTextContent(&widget, p)
+	lk.applyLinks(&widget, p)
+	ids.assignWidgetIDs(&widget, key)
+	widget.GrafanaPanelID = p.ID
+	return widget
 }
 
 func collectExprs(ts []parser.GrafanaTarget, reps []Replacement) []string {
@@ -249,62 +354,6 @@ func nonEmpty(s, def string) string {
 	return s
 }
 
-// buildVariables converts Grafana variables into SigNoz-like variable objects (best-effort).
-func buildVariables(g *parser.GrafanaDashboard) map[string]interface{} {
-	out := map[string]interface{}{}
-	for i, v := range g.Templating.List {
-		id := newUUID()
-		typ := strings.ToUpper(v.Type)
-		if typ == "" {
-			typ = "TEXT"
-		}
-		name := v.Name
-		if name == "" {
-			name = fmt.Sprintf("var_%d", i)
-		}
-		out[id] = map[string]interface{}{
-			"id":               id,
-			"name":             name,
-			"type":             typ,
-			"modificationUUID": newUUID(),
-			"queryValue":       v.Query,
-			"multiSelect":      v.Multi,
-			"showALLOption":    v.IncludeAll,
-			"order":            i,
-			"description":      v.Label,
-			"sort":             "DISABLED",
-			"customValue":      "",
-			"textboxValue":     "",
-			"allSelected":      false,
-		}
-	}
-	return out
-}
-
-// newUUID returns a pseudo-random UUID v4 string.
-func newUUID() string {
-	b := make([]byte, 16)
-	if _, err := crand.Read(b); err != nil {
-		// very unlikely; fallback to zeros which still produce a string
-	}
-	// Set version and variant bits
-	b[6] = (b[6] & 0x0f) | 0x40
-	b[8] = (b[8] & 0x3f) | 0x80
-	hex := func(x byte) string {
-		const hexdigits = "0123456789abcdef"
-		return string([]byte{hexdigits[x>>4], hexdigits[x&0x0f]})
-	}
-	sb := strings.Builder{}
-	for i, v := range b {
-		sb.WriteString(hex(v))
-		switch i {
-		case 3, 5, 7, 9:
-			sb.WriteByte('-')
-		}
-	}
-	return sb.String()
-}
-
 // ---------- PromQL -> SigNoz Builder mapping ----------
 
 type labelMatcher struct {
@@ -331,13 +380,10 @@ type promQL struct {
 	CmpIsBool bool   // whether 'bool' modifier was used
 }
 
-func makeSigNozQueryFromTargets(ts []parser.GrafanaTarget, rules *Rules) map[string]interface{} {
-	// Defaults
-	queryID := newUUID()
-
-	// Build queryData slice, and promql entries from grafana targets
-	qd := make([]interface{}, 0, len(ts))
-	promql := make([]map[string]interface{}, 0, int(math.Max(1, float64(len(ts)))))
+func makeSigNozQueryFromTargets(ts []parser.GrafanaTarget) Query {
+	// Build queryData and promql entries from grafana targets
+	qd := make([]BuilderQuery, 0, len(ts))
+	promql := make([]PromQLQuery, 0, int(math.Max(1, float64(len(ts)))))
 	for _, t := range ts {
 		expr := strings.TrimSpace(t.Expr)
 		if expr == "" {
@@ -345,71 +391,68 @@ func makeSigNozQueryFromTargets(ts []parser.GrafanaTarget, rules *Rules) map[str
 		}
 		p := parsePromQL(expr)
 		// Convert to builder query item
-		qitem := map[string]interface{}{
-			"aggregateAttribute": map[string]interface{}{
-				"dataType": "float64",
-				"id":       fmt.Sprintf("%s--float64--%s--true", p.Metric, guessMetricType(p)),
-				"isColumn": true,
-				"isJSON":   false,
-				"key":      p.Metric,
-				"type":     guessMetricType(p),
+		qitem := BuilderQuery{
+			AggregateAttribute: AggregateAttribute{
+				DataType: "float64",
+				ID:       fmt.Sprintf("%s--float64--%s--true", p.Metric, guessMetricType(p)),
+				IsColumn: true,
+				IsJSON:   false,
+				Key:      p.Metric,
+				Type:     guessMetricType(p),
 			},
-			"aggregateOperator": pickAggOperator(p),
-			"dataSource":        "metrics",
-			"disabled":          false,
-			"expression":        nonEmpty(t.RefID, "A"),
-			"filters": map[string]interface{}{
-				"items": buildFilterItems(p.Labels),
-				"op":    "AND",
+			AggregateOperator: pickAggOperator(p),
+			DataSource:        "metrics",
+			Disabled:          t.Hide,
+			Expression:        nonEmpty(t.RefID, "A"),
+			Filters: FilterSet{
+				Items: buildFilterItems(p.Labels),
+				Op:    "AND",
 			},
-			"functions":        buildFunctions(p),
-			"groupBy":          buildGroupBy(p, t.LegendFormat),
-			"having":           []interface{}{},
-			"legend":           nonEmpty(t.LegendFormat, ""),
-			"limit":            nil,
-			"orderBy":          []interface{}{},
-			"queryName":        nonEmpty(t.RefID, "A"),
-			"reduceTo":         "avg",
-			"spaceAggregation": "sum",
-			"stepInterval":     60,
-			"timeAggregation":  pickTimeAggregation(p),
+			Functions:        buildFunctions(p),
+			GroupBy:          buildGroupBy(p, t.LegendFormat),
+			Having:           []Having{},
+			Legend:           nonEmpty(t.LegendFormat, ""),
+			Limit:            nil,
+			OrderBy:          []OrderBy{},
+			QueryName:        nonEmpty(t.RefID, "A"),
+			ReduceTo:         "avg",
+			SpaceAggregation: "sum",
+			StepInterval:     stepInterval(t.Interval),
+			TimeAggregation:  pickTimeAggregation(p),
 		}
 		// Add comparison as HAVING when possible
 		if p.CmpOp != "" && p.CmpRight != "" {
-			qitem["having"] = []interface{}{
-				map[string]interface{}{
-					"columnName": "#SIGNOZ_VALUE",
-					"op":         p.CmpOp,
-					"value":      p.CmpRight,
-				},
-			}
+			qitem.Having = []Having{{
+				ColumnName: "#SIGNOZ_VALUE",
+				Op:         p.CmpOp,
+				Value:      p.CmpRight,
+			}}
 		}
 		qd = append(qd, qitem)
-		promql = append(promql, map[string]interface{}{
-			"disabled": false,
-			"legend":   nonEmpty(t.LegendFormat, ""),
-			"name":     nonEmpty(t.RefID, "A"),
-			"query":    expr,
+		promql = append(promql, PromQLQuery{
+			Disabled: t.Hide,
+			Legend:   nonEmpty(t.LegendFormat, ""),
+			Name:     nonEmpty(t.RefID, "A"),
+			Query:    expr,
 		})
 	}
 
-	return map[string]interface{}{
-		"queryType": "builder",
-		"builder": map[string]interface{}{
-			"queryData":     qd,
-			"queryFormulas": []interface{}{},
+	return Query{
+		QueryType: "builder",
+		Builder: Builder{
+			QueryData:     qd,
+			QueryFormulas: []Formula{},
 		},
-		"promql": promql,
-		"clickhouse_sql": []map[string]interface{}{{
-			"disabled": false, "legend": "", "name": "A", "query": "",
+		PromQL: promql,
+		ClickHouseSQL: []ClickHouseQuery{{
+			Disabled: false, Legend: "", Name: "A", Query: "",
 		}},
-		"id":            queryID,
-		"_grafanaExprs": collectExprs(ts, rules.QueryReplacements),
+		ID: newUUID(),
 	}
 }
 
-func buildFilterItems(ms []labelMatcher) []interface{} {
-	out := make([]interface{}, 0, len(ms))
+func buildFilterItems(ms []labelMatcher) []FilterItem {
+	out := make([]FilterItem, 0, len(ms))
 	for _, m := range ms {
 		op := m.Op
 		switch op {
@@ -420,54 +463,58 @@ func buildFilterItems(ms []labelMatcher) []interface{} {
 		default:
 			// keep = or !=
 		}
-		out = append(out, map[string]interface{}{
-			"id": fmt.Sprintf("f_%s", m.Key),
-			"key": map[string]interface{}{
-				"dataType": "string",
-				"id":       fmt.Sprintf("%s--string--tag--true", m.Key),
-				"isColumn": true,
-				"isJSON":   false,
-				"key":      m.Key,
-				"type":     "tag",
-			},
-			"op":    op,
-			"value": toSigNozTmpl(m.Value),
+		out = append(out, FilterItem{
+			ID:    fmt.Sprintf("f_%s", m.Key),
+			Key:   tagKey(m.Key),
+			Op:    op,
+			Value: toSigNozTmpl(m.Value),
 		})
 	}
 	return out
 }
 
-func buildGroupBy(p promQL, legend string) []interface{} {
-	// Prefer explicit by() labels; otherwise infer from legend placeholders
-	labels := map[string]bool{}
+func buildGroupBy(p promQL, legend string) []GroupByKey {
+	// Prefer explicit by() labels; otherwise infer from legend placeholders.
+	// Keys keep their order of appearance so conversions are reproducible.
+	var labels []string
+	add := func(k string) {
+		if !contains(labels, k) {
+			labels = append(labels, k)
+		}
+	}
 	for _, b := range p.By {
-		labels[b] = true
+		add(b)
 	}
 	for _, ph := range legendPlaceholders(legend) {
-		labels[ph] = true
+		add(ph)
 	}
 	// If none collected but we have label matchers with template values, group by those labels
 	if len(labels) == 0 {
 		for _, m := range p.Labels {
 			if looksLikeTemplate(m.Value) {
-				labels[m.Key] = true
+				add(m.Key)
 			}
 		}
 	}
-	out := make([]interface{}, 0, len(labels))
-	for k := range labels {
-		out = append(out, map[string]interface{}{
-			"dataType": "string",
-			"id":       fmt.Sprintf("%s--string--tag--true", k),
-			"isColumn": true,
-			"isJSON":   false,
-			"key":      k,
-			"type":     "tag",
-		})
+	out := make([]GroupByKey, 0, len(labels))
+	for _, k := range labels {
+		out = append(out, tagKey(k))
 	}
 	return out
 }
 
+// tagKey returns the attribute key used for string tag filters and groupings.
+func tagKey(k string) AttributeKey {
+	return AttributeKey{
+		DataType: "string",
+		ID:       fmt.Sprintf("%s--string--tag--true", k),
+		IsColumn: true,
+		IsJSON:   false,
+		Key:      k,
+		Type:     "tag",
+	}
+}
+
 func pickAggOperator(p promQL) string {
 	if p.Agg != "" {
 		return p.Agg
@@ -578,18 +625,18 @@ func innerFuncForHist(p promQL) string {
 	return p.Func
 }
 
-func buildFunctions(p promQL) []interface{} {
-	funcs := []interface{}{}
+func buildFunctions(p promQL) []Function {
+	funcs := []Function{}
 	if p.Func == "histogram_quantile" {
-		funcs = append(funcs, map[string]interface{}{
-			"name": "histogram_quantile",
-			"args": map[string]interface{}{"q": p.Quantile, "leLabel": "le"},
+		funcs = append(funcs, Function{
+			Name: "histogram_quantile",
+			Args: map[string]interface{}{"q": p.Quantile, "leLabel": "le"},
 		})
 	}
 	if p.Offset != "" {
-		funcs = append(funcs, map[string]interface{}{
-			"name": "offset",
-			"args": map[string]interface{}{"duration": p.Offset},
+		funcs = append(funcs, Function{
+			Name: "offset",
+			Args: map[string]interface{}{"duration": p.Offset},
 		})
 	}
 	return funcs
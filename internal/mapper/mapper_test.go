@@ -1,7 +1,11 @@
 package mapper
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 
 	"grafana2signoz/internal/parser"
@@ -54,23 +58,1069 @@ func TestPromQLSelectorToBuilder(t *testing.T) {
 	if len(sd.Widgets) != 1 {
 		t.Fatalf("widgets=%d", len(sd.Widgets))
 	}
-	q := sd.Widgets[0].Query
-	b, ok := q["builder"].(map[string]interface{})
-	if !ok {
-		t.Fatalf("no builder")
-	}
-	qd, ok := b["queryData"].([]interface{})
-	if !ok || len(qd) != 1 {
+	qd := sd.Widgets[0].Query.Builder.QueryData
+	if len(qd) != 1 {
 		t.Fatalf("queryData len")
 	}
-	item := qd[0].(map[string]interface{})
-	aggAttr := item["aggregateAttribute"].(map[string]interface{})
-	if aggAttr["key"].(string) != "nodejs_eventloop_lag_seconds" {
-		t.Fatalf("metric=%v", aggAttr["key"])
+	if got := qd[0].AggregateAttribute.Key; got != "nodejs_eventloop_lag_seconds" {
+		t.Fatalf("metric=%v", got)
 	}
-	filters := item["filters"].(map[string]interface{})
-	items := filters["items"].([]interface{})
+	items := qd[0].Filters.Items
 	if len(items) != 1 {
 		t.Fatalf("filters=%v", len(items))
 	}
 }
+
+func TestGraphOptions(t *testing.T) {
+	fill, noFill := 5, 0
+	gd := &parser.GrafanaDashboard{
+		Title: "T",
+		Panels: []parser.GrafanaPanel{
+			{
+				ID:       1,
+				Type:     "timeseries",
+				Title:    "Bars",
+				GridPos:  &parser.GrafanaGridPos{X: 0, Y: 0, W: 12, H: 8},
+				FieldCfg: []byte(`{"defaults":{"custom":{"drawStyle":"bars","fillOpacity":30,"spanNulls":true,"stacking":{"mode":"normal"}}}}`),
+			},
+			{
+				ID:            2,
+				Type:          "graph",
+				Title:         "Legacy",
+				Stack:         true,
+				Fill:          &fill,
+				NullPointMode: "null as zero",
+				GridPos:       &parser.GrafanaGridPos{X: 0, Y: 10, W: 12, H: 8},
+			},
+			{
+				ID:       3,
+				Type:     "timeseries",
+				Title:    "No fill",
+				FieldCfg: []byte(`{"defaults":{"custom":{"fillOpacity":0}}}`),
+				GridPos:  &parser.GrafanaGridPos{X: 0, Y: 20, W: 12, H: 8},
+			},
+			{ID: 4, Type: "graph", Title: "Legacy no fill", Fill: &noFill, GridPos: &parser.GrafanaGridPos{X: 12, Y: 20, W: 12, H: 8}},
+		},
+	}
+	rules := DefaultRules()
+	sd := GrafanaToSigNoz(gd, &rules)
+	bars := sd.Widgets[0]
+	if bars.PanelType != "bar" || !bars.IsStacked || !bars.StackedBarChart || bars.Opacity != "0.3" || !bars.FillSpans {
+		t.Fatalf("bars widget=%+v", bars)
+	}
+	legacy := sd.Widgets[1]
+	if legacy.PanelType != "graph" || !legacy.IsStacked || legacy.Opacity != "0.5" || legacy.NullZeroValues != "zero" {
+		t.Fatalf("legacy widget=%+v", legacy)
+	}
+	for _, w := range sd.Widgets[2:] {
+		if w.Opacity != "1" {
+			t.Fatalf("%s opacity = %q", w.Title, w.Opacity)
+		}
+	}
+}
+
+func TestReduceTo(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title: "T",
+		Panels: []parser.GrafanaPanel{
+			{
+				ID:      1,
+				Type:    "stat",
+				Title:   "Current memory",
+				GridPos: &parser.GrafanaGridPos{X: 0, Y: 0, W: 6, H: 4},
+				Options: []byte(`{"reduceOptions":{"calcs":["lastNotNull"]}}`),
+				Targets: []parser.GrafanaTarget{{RefID: "A", Expr: "process_resident_memory_bytes"}},
+			},
+			{
+				ID:        2,
+				Type:      "singlestat",
+				Title:     "Restarts",
+				GridPos:   &parser.GrafanaGridPos{X: 6, Y: 0, W: 6, H: 4},
+				ValueName: "total",
+				Targets:   []parser.GrafanaTarget{{RefID: "A", Expr: "process_start_time_seconds"}},
+			},
+			{
+				ID:      3,
+				Type:    "timeseries",
+				Title:   "Memory",
+				GridPos: &parser.GrafanaGridPos{X: 12, Y: 0, W: 12, H: 4},
+				Options: []byte(`{"reduceOptions":{"calcs":["max"]}}`),
+				Targets: []parser.GrafanaTarget{{RefID: "A", Expr: "process_resident_memory_bytes"}},
+			},
+		},
+	}
+	rules := DefaultRules()
+	sd := GrafanaToSigNoz(gd, &rules)
+	for i, want := range []string{"last", "sum", "avg"} {
+		if got := sd.Widgets[i].Query.Builder.QueryData[0].ReduceTo; got != want {
+			t.Fatalf("widget %d reduceTo=%q want %q", i, got, want)
+		}
+	}
+}
+
+func TestValueMappings(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title: "T",
+		Panels: []parser.GrafanaPanel{{
+			ID:    1,
+			Type:  "stat",
+			Title: "Status",
+			FieldCfg: []byte(`{"defaults":{"mappings":[
+				{"type":"value","options":{"1":{"text":"UP","color":"green"},"0":{"text":"DOWN","color":"red"}}},
+				{"type":"range","options":{"from":2,"to":null,"result":{"text":"DEGRADED"}}},
+				{"type":"special","options":{"match":"null","result":{"text":"N/A"}}}
+			]}}`),
+			Targets: []parser.GrafanaTarget{{RefID: "A", Expr: "up"}},
+		}},
+	}
+	rules := DefaultRules()
+	w := GrafanaToSigNoz(gd, &rules).Widgets[0]
+	if len(w.Thresholds) != 3 {
+		t.Fatalf("thresholds=%+v", w.Thresholds)
+	}
+	down := w.Thresholds[0]
+	if down.ThresholdOperator != "=" || down.ThresholdValue != 0 || down.ThresholdLabel != "DOWN" || down.ThresholdColor != "#F2495C" {
+		t.Fatalf("down=%+v", down)
+	}
+	if r := w.Thresholds[2]; r.ThresholdOperator != ">=" || r.ThresholdValue != 2 {
+		t.Fatalf("range=%+v", r)
+	}
+	if len(w.Warnings) != 1 {
+		t.Fatalf("warnings=%v", w.Warnings)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title: "T",
+		Panels: []parser.GrafanaPanel{{
+			ID:    1,
+			Type:  "timeseries",
+			Title: "Memory",
+			FieldCfg: []byte(`{"defaults":{"unit":"bytes"},"overrides":[
+				{"matcher":{"id":"byFrameRefID","options":"B"},"properties":[
+					{"id":"displayName","value":"Heap"},
+					{"id":"unit","value":"percent"},
+					{"id":"color","value":{"mode":"fixed","fixedColor":"red"}}
+				]},
+				{"matcher":{"id":"byName","options":"RSS"},"properties":[{"id":"custom.hideFrom","value":{"viz":true,"legend":false,"tooltip":false}}]},
+				{"matcher":{"id":"byValue","options":{}},"properties":[{"id":"unit","value":"s"}]},
+				{"matcher":{"id":"byFrameRefID","options":"A"},"properties":[{"id":"custom.lineWidth","value":2}]}
+			]}`),
+			Targets: []parser.GrafanaTarget{
+				{RefID: "A", Expr: "process_resident_memory_bytes", LegendFormat: "RSS"},
+				{RefID: "B", Expr: "nodejs_heap_size_used_bytes"},
+			},
+		}},
+	}
+	rules := DefaultRules()
+	sd, report := Convert(gd, &rules)
+	w := sd.Widgets[0]
+	qd := w.Query.Builder.QueryData
+	if w.YAxisUnit != "bytes" || w.ColumnUnits["B"] != "percent" {
+		t.Fatalf("units: y=%q columns=%v", w.YAxisUnit, w.ColumnUnits)
+	}
+	if qd[1].Legend != "Heap" || w.Query.PromQL[1].Legend != "Heap" {
+		t.Fatalf("legend=%q", qd[1].Legend)
+	}
+	if w.CustomLegendColors["Heap"] != "#F2495C" {
+		t.Fatalf("colors=%v", w.CustomLegendColors)
+	}
+	if !qd[0].Disabled {
+		t.Fatalf("RSS series not disabled")
+	}
+	if len(report.Items) != 2 {
+		t.Fatalf("report=%+v", report.Items)
+	}
+}
+
+func TestTableTransformations(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title: "T",
+		Panels: []parser.GrafanaPanel{{
+			ID:      1,
+			Type:    "table",
+			Title:   "Instances",
+			Targets: []parser.GrafanaTarget{{RefID: "A", Expr: "sum(up) by (instance)", Format: "table"}},
+			Transformations: []parser.GrafanaTransformation{
+				{ID: "organize", Options: []byte(`{"excludeByName":{"Time":true},"renameByName":{"Value":"Up"}}`)},
+			},
+		}},
+	}
+	rules := DefaultRules()
+	tbl := GrafanaToSigNoz(gd, &rules).Widgets[0]
+	if tbl.PanelType != "table" {
+		t.Fatalf("widget type=%s", tbl.PanelType)
+	}
+	q := tbl.Query.Builder.QueryData[0]
+	if q.Legend != "Up" || q.ReduceTo != "last" || len(tbl.Warnings) != 0 {
+		t.Fatalf("legend=%q reduceTo=%q warnings=%v", q.Legend, q.ReduceTo, tbl.Warnings)
+	}
+
+	gd = &parser.GrafanaDashboard{
+		Title: "T",
+		Panels: []parser.GrafanaPanel{{
+			ID:       1,
+			Type:     "table",
+			Title:    "Pods",
+			FieldCfg: []byte(`{"defaults":{"unit":"bytes"}}`),
+			Targets: []parser.GrafanaTarget{
+				{RefID: "A", Expr: "sum by (pod) (container_memory_usage_bytes)"},
+				{RefID: "B", Expr: "sum by (pod) (kube_pod_container_resource_limits)"},
+				{RefID: "C", Expr: "sum by (pod) (kube_pod_container_resource_requests)"},
+			},
+			Transformations: []parser.GrafanaTransformation{
+				{ID: "seriesToColumns", Options: []byte(`{"byField":"pod"}`)},
+				{ID: "reduce", Options: []byte(`{"reducers":["max"]}`)},
+				{ID: "organize", Options: []byte(`{"indexByName":{"Value #B":0,"Value #A":1},"excludeByName":{"Value #C":true},"renameByName":{"pod":"Pod"}}`)},
+				{ID: "renameByRegex", Options: []byte(`{"regex":"Value #(.*)","renamePattern":"Query $1"}`)},
+				{ID: "calculateField", Options: []byte(`{}`)},
+			},
+		}},
+	}
+	w := GrafanaToSigNoz(gd, &rules).Widgets[0]
+	qd := w.Query.Builder.QueryData
+	if qd[0].QueryName != "B" || qd[1].QueryName != "A" {
+		t.Fatalf("order=%s,%s", qd[0].QueryName, qd[1].QueryName)
+	}
+	if qd[0].Legend != "Query B" || qd[0].ReduceTo != "max" || !qd[2].Disabled {
+		t.Fatalf("queries=%+v", qd)
+	}
+	if w.ColumnUnits["A"] != "bytes" {
+		t.Fatalf("columnUnits=%v", w.ColumnUnits)
+	}
+	// Label rename and calculateField cannot be expressed.
+	if len(w.Warnings) != 2 {
+		t.Fatalf("warnings=%v", w.Warnings)
+	}
+}
+
+func TestRowsAndPanelMap(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title: "T",
+		Panels: []parser.GrafanaPanel{
+			{ID: 1, Type: "stat", Title: "Top", GridPos: &parser.GrafanaGridPos{X: 0, Y: 0, W: 6, H: 4}},
+			{ID: 20, Type: "row", Title: "Expanded", RowKey: 1, GridPos: &parser.GrafanaGridPos{X: 0, Y: 4, W: 24, H: 1}},
+			{ID: 2, Type: "graph", Title: "In expanded", RowID: 1, GridPos: &parser.GrafanaGridPos{X: 0, Y: 5, W: 12, H: 8}},
+			{ID: 10, Type: "row", Title: "Collapsed", Collapsed: true, RowKey: 2, GridPos: &parser.GrafanaGridPos{X: 0, Y: 13, W: 24, H: 1}},
+			{ID: 3, Type: "graph", Title: "In collapsed", RowID: 2, GridPos: &parser.GrafanaGridPos{X: 0, Y: 14, W: 12, H: 8}},
+		},
+	}
+	rules := DefaultRules()
+	sd := GrafanaToSigNoz(gd, &rules)
+	if len(sd.Widgets) != 5 {
+		t.Fatalf("widgets=%d", len(sd.Widgets))
+	}
+	// The collapsed row's member is not part of the layout.
+	if len(sd.Layout) != 4 {
+		t.Fatalf("layout=%+v", sd.Layout)
+	}
+	id := widgetIDs(sd)
+	row := sd.Layout[1]
+	if row.I != id[20] || row.H != 1 || row.MaxH != 1 || row.MinH != 1 || row.MinW != row.W {
+		t.Fatalf("row layout=%+v", row)
+	}
+	exp, col := sd.PanelMap[id[20]], sd.PanelMap[id[10]]
+	if exp.Collapsed || len(exp.Widgets) != 1 || exp.Widgets[0].I != id[2] {
+		t.Fatalf("expanded=%+v", exp)
+	}
+	if !col.Collapsed || len(col.Widgets) != 1 || col.Widgets[0].I != id[3] {
+		t.Fatalf("collapsed=%+v", col)
+	}
+	b, err := json.Marshal(sd.Widgets[1])
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(b) != `{"id":"`+id[20]+`","panelTypes":"row","title":"Expanded","description":"","_grafanaPanelId":20}` {
+		t.Fatalf("row widget json=%s", b)
+	}
+}
+
+// widgetIDs maps Grafana panel ids to the ids of their widgets.
+func TestRowsWithSharedOrMissingIDs(t *testing.T) {
+	cases := map[string]string{
+		"shared": `{"title": "Shared", "panels": [
+  {"id": 5, "type": "row", "title": "A", "gridPos": {"h": 1, "w": 24, "x": 0, "y": 0}},
+  {"id": 1, "type": "graph", "title": "In A", "gridPos": {"h": 8, "w": 12, "x": 0, "y": 1}},
+  {"id": 5, "type": "row", "title": "B", "collapsed": true, "gridPos": {"h": 1, "w": 24, "x": 0, "y": 9},
+   "panels": [{"id": 2, "type": "graph", "title": "In B", "gridPos": {"h": 8, "w": 12, "x": 0, "y": 10}}]}]}`,
+		"missing": `{"title": "Missing", "panels": [
+  {"type": "row", "title": "A", "gridPos": {"h": 1, "w": 24, "x": 0, "y": 0}},
+  {"id": 1, "type": "graph", "title": "In A", "gridPos": {"h": 8, "w": 12, "x": 0, "y": 1}},
+  {"type": "row", "title": "B", "collapsed": true, "gridPos": {"h": 1, "w": 24, "x": 0, "y": 9},
+   "panels": [{"id": 2, "type": "graph", "title": "In B", "gridPos": {"h": 8, "w": 12, "x": 0, "y": 10}}]}]}`,
+	}
+	for name, in := range cases {
+		gd, err := parser.ParseGrafanaDashboard(strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("%s: parse: %v", name, err)
+		}
+		sd, _ := Convert(gd, nil)
+		var titles []string
+		for _, w := range sd.Widgets {
+			titles = append(titles, w.Title)
+		}
+		if got := strings.Join(titles, "|"); got != "A|In A|B|In B" {
+			t.Fatalf("%s: titles = %s", name, got)
+		}
+		// In B is in the collapsed row, so only in the panelMap.
+		if len(sd.Layout) != 3 || sd.Layout[1].I != sd.Widgets[1].ID {
+			t.Fatalf("%s: layout = %+v", name, sd.Layout)
+		}
+		a, b := sd.PanelMap[sd.Widgets[0].ID], sd.PanelMap[sd.Widgets[2].ID]
+		if len(sd.PanelMap) != 2 || len(a.Widgets) != 1 || a.Widgets[0].I != sd.Widgets[1].ID || len(b.Widgets) != 1 || b.Widgets[0].I != sd.Widgets[3].ID {
+			t.Fatalf("%s: panelMap = %+v", name, sd.PanelMap)
+		}
+	}
+}
+
+func widgetIDs(sd SigNozDashboard) map[int]string {
+	out := map[int]string{}
+	for _, w := range sd.Widgets {
+		out[w.GrafanaPanelID] = w.ID
+	}
+	return out
+}
+
+func TestLayoutScalesToTwelveColumns(t *testing.T) {
+	gd, err := parser.ParseGrafanaDashboardFile("../../testdata/grafana-dasboards/node-application.json")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	rules := DefaultRules()
+	sd := GrafanaToSigNoz(gd, &rules)
+	byID := map[string]SigNozLayout{}
+	for i, l := range sd.Layout {
+		if l.X < 0 || l.W < 1 || l.X+l.W > 12 {
+			t.Fatalf("layout %s outside grid: %+v", l.I, l)
+		}
+		for _, o := range sd.Layout[:i] {
+			if overlaps(l, o) {
+				t.Fatalf("layout %s overlaps %s", l.I, o.I)
+			}
+		}
+		byID[l.I] = l
+	}
+	// Process CPU (0,0,w10), Event Loop Lag (10,0,w9), Node.js Version (19,0,w5).
+	ids := widgetIDs(sd)
+	for pid, want := range map[int][2]int{6: {0, 5}, 8: {5, 5}, 2: {10, 2}} {
+		if id, l := ids[pid], byID[ids[pid]]; l.X != want[0] || l.W != want[1] || l.Y != 0 {
+			t.Fatalf("%s: %+v, want x=%d w=%d y=0", id, l, want[0], want[1])
+		}
+	}
+}
+
+func TestLayoutResolvesCollisions(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title: "T",
+		Panels: []parser.GrafanaPanel{
+			{ID: 1, Type: "graph", Title: "A", GridPos: &parser.GrafanaGridPos{X: 0, Y: 0, W: 12, H: 8}},
+			{ID: 2, Type: "graph", Title: "B", GridPos: &parser.GrafanaGridPos{X: 6, Y: 4, W: 12, H: 8}},
+			{ID: 3, Type: "graph", Title: "C"},
+		},
+	}
+	rules := DefaultRules()
+	sd := GrafanaToSigNoz(gd, &rules)
+	a, b, c := sd.Layout[0], sd.Layout[1], sd.Layout[2]
+	if a.X != 0 || a.W != 6 || a.Y != 0 {
+		t.Fatalf("a=%+v", a)
+	}
+	if b.X != 3 || b.W != 6 || b.Y != 8 {
+		t.Fatalf("b=%+v", b)
+	}
+	// Panels without gridPos go below the positioned ones.
+	if c.Y < b.Y+b.H || c.W != rules.DefaultWidth {
+		t.Fatalf("c=%+v", c)
+	}
+}
+
+func repeatDashboard() *parser.GrafanaDashboard {
+	return &parser.GrafanaDashboard{
+		Title: "T",
+		Templating: parser.GrafanaTemplate{List: []parser.GrafanaVariable{{
+			Name:    "instance",
+			Type:    "query",
+			Current: map[string]interface{}{"text": "All", "value": []interface{}{"$__all"}},
+			Options: []parser.GrafanaVariableOption{
+				{Text: "All", Value: "$__all"},
+				{Text: "a:9100", Value: "a:9100"},
+				{Text: "b:9100", Value: "b:9100"},
+			},
+		}}},
+		Panels: []parser.GrafanaPanel{
+			{
+				ID: 1, Type: "graph", Title: "CPU $instance", Repeat: "instance", RepeatDirection: "h",
+				GridPos: &parser.GrafanaGridPos{X: 0, Y: 0, W: 24, H: 8},
+				Targets: []parser.GrafanaTarget{{RefID: "A", Expr: `rate(cpu_seconds_total{instance=~"$instance"}[5m])`}},
+			},
+			{ID: 2, Type: "graph", Title: "Below", GridPos: &parser.GrafanaGridPos{X: 0, Y: 8, W: 24, H: 8}},
+		},
+	}
+}
+
+func TestRepeatExpand(t *testing.T) {
+	rules := DefaultRules()
+	rules.RepeatMode = RepeatExpand
+	sd := GrafanaToSigNoz(repeatDashboard(), &rules)
+	if len(sd.Widgets) != 3 {
+		t.Fatalf("widgets=%d", len(sd.Widgets))
+	}
+	if sd.Widgets[0].Title != "CPU a:9100" || sd.Widgets[1].Title != "CPU b:9100" {
+		t.Fatalf("titles=%q,%q", sd.Widgets[0].Title, sd.Widgets[1].Title)
+	}
+	if v := sd.Widgets[1].Query.Builder.QueryData[0].Filters.Items[0].Value; v != "b:9100" {
+		t.Fatalf("filter value=%v", v)
+	}
+	a, b, below := sd.Layout[0], sd.Layout[1], sd.Layout[2]
+	if a.Y != 0 || b.Y != 0 || a.W != 6 || b.X != 6 || below.Y != 8 {
+		t.Fatalf("layout=%+v", sd.Layout)
+	}
+}
+
+func TestRepeatCopiesWideMaxPerRow(t *testing.T) {
+	vals := make([]string, 30)
+	for i := range vals {
+		vals[i] = fmt.Sprint(i)
+	}
+	p := parser.GrafanaPanel{ID: 1, Type: "graph", Title: "CPU $v", Repeat: "v", MaxPerRow: 48, GridPos: &parser.GrafanaGridPos{W: 24, H: 8}}
+	id := 100
+	copies, extra := repeatCopies(p, vals, func() int { id++; return id })
+	if len(copies) != 30 || extra != 8 {
+		t.Fatalf("copies=%d extra=%d", len(copies), extra)
+	}
+	for k, c := range copies {
+		if g := c.GridPos; g.W != 1 || g.X != k%24 || g.Y != k/24*8 {
+			t.Fatalf("copy %d gridPos=%+v", k, *g)
+		}
+	}
+}
+
+func TestRepeatGroup(t *testing.T) {
+	rules := DefaultRules()
+	sd := GrafanaToSigNoz(repeatDashboard(), &rules)
+	if len(sd.Widgets) != 2 {
+		t.Fatalf("widgets=%d", len(sd.Widgets))
+	}
+	q := sd.Widgets[0].Query.Builder.QueryData[0]
+	if !hasGroupBy(q, "instance") || q.Legend != "{{instance}}" {
+		t.Fatalf("query=%+v", q)
+	}
+}
+
+func TestRepeatRowExpand(t *testing.T) {
+	gd := repeatDashboard()
+	gd.Panels = []parser.GrafanaPanel{
+		{ID: 10, Type: "row", Title: "Host $instance", Repeat: "instance", RowKey: 1, GridPos: &parser.GrafanaGridPos{X: 0, Y: 0, W: 24, H: 1}},
+		{ID: 1, Type: "graph", Title: "CPU", RowID: 1, GridPos: &parser.GrafanaGridPos{X: 0, Y: 1, W: 24, H: 8},
+			Targets: []parser.GrafanaTarget{{RefID: "A", Expr: `up{instance="$instance"}`}}},
+		{ID: 20, Type: "row", Title: "Other", RowKey: 2, GridPos: &parser.GrafanaGridPos{X: 0, Y: 9, W: 24, H: 1}},
+	}
+	rules := DefaultRules()
+	rules.RepeatMode = RepeatExpand
+	sd := GrafanaToSigNoz(gd, &rules)
+	var titles []string
+	for _, w := range sd.Widgets {
+		titles = append(titles, w.Title)
+	}
+	want := []string{"Host a:9100", "CPU", "Host b:9100", "CPU", "Other"}
+	if strings.Join(titles, "|") != strings.Join(want, "|") {
+		t.Fatalf("titles=%v", titles)
+	}
+	if len(sd.PanelMap) != 3 {
+		t.Fatalf("panelMap=%v", sd.PanelMap)
+	}
+	for i := 1; i < len(sd.Layout); i++ {
+		if sd.Layout[i].Y < sd.Layout[i-1].Y {
+			t.Fatalf("layout out of order: %+v", sd.Layout)
+		}
+	}
+}
+
+func TestMissingLibraryPanelReported(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title: "L",
+		Panels: []parser.GrafanaPanel{{
+			ID:           4,
+			GridPos:      &parser.GrafanaGridPos{H: 8, W: 12},
+			LibraryPanel: &parser.GrafanaLibraryPanelRef{UID: "u1", Name: "Disk"},
+		}},
+	}
+	sd, report := Convert(gd, nil)
+	if sd.Widgets[0].Title != "Disk" {
+		t.Fatalf("title = %q", sd.Widgets[0].Title)
+	}
+	if len(report.Items) != 1 || !strings.Contains(report.Items[0].Message, `library panel "Disk" (uid u1) not found`) {
+		t.Fatalf("report = %+v", report.Items)
+	}
+	// Missing references returned by the parser are added once.
+	report.AddMissingLibraryPanels([]parser.GrafanaLibraryPanelRef{{UID: "u1", Name: "Disk"}, {UID: "u2", Name: "CPU"}})
+	if len(report.Items) != 2 || report.Items[1].Widget != "" || !strings.Contains(report.Items[1].Message, `library panel "CPU" (uid u2) not found`) {
+		t.Fatalf("report = %+v", report.Items)
+	}
+}
+
+func TestDashboardMetadata(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title:       "M",
+		UID:         "abc",
+		Description: "Cluster overview",
+		Tags:        []string{"k8s", "grafana"},
+		Meta:        &parser.GrafanaMeta{FolderUID: "f1", FolderTitle: "Platform", Version: 7},
+		Requires:    []parser.GrafanaRequire{{Type: "panel", ID: "grafana-worldmap-panel", Name: "Worldmap"}, {Type: "panel", ID: "stat"}},
+	}
+	sd, report := Convert(gd, nil)
+	if got := strings.Join(sd.Tags, ","); got != "migrated,grafana,k8s,folder:Platform" {
+		t.Fatalf("tags = %s", got)
+	}
+	if sd.Description != "Cluster overview" {
+		t.Fatalf("description = %q", sd.Description)
+	}
+	if src := sd.Grafana; src == nil || src.UID != "abc" || src.FolderTitle != "Platform" || src.Version != 7 {
+		t.Fatalf("source = %+v", sd.Grafana)
+	}
+	if len(report.Items) != 1 || !strings.Contains(report.Items[0].Message, "grafana-worldmap-panel") {
+		t.Fatalf("report = %+v", report.Items)
+	}
+}
+
+func TestDatasourceClassification(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Title:  "DS",
+		Inputs: []parser.GrafanaInput{{Name: "DS_PROMETHEUS", Type: "datasource", PluginID: "prometheus"}},
+		Templating: parser.GrafanaTemplate{List: []parser.GrafanaVariable{
+			{Name: "logs", Type: "datasource", Query: "loki"},
+			{Name: "job", Type: "query", Query: "label_values(up, job)"},
+		}},
+		Panels: []parser.GrafanaPanel{{
+			ID:         1,
+			Type:       "timeseries",
+			Datasource: map[string]interface{}{"type": "datasource", "uid": "-- Mixed --"},
+			Targets: []parser.GrafanaTarget{
+				{RefID: "A", Expr: "up", Datasource: "${DS_PROMETHEUS}"},
+				{RefID: "B", Expr: `count_over_time({job="x"}[5m])`, Datasource: map[string]interface{}{"uid": "$logs"}},
+				{RefID: "C", Expr: "rate(x_total[5m])", Datasource: "${DS_OTHER}"},
+				{RefID: "D", Expr: "select 1", Datasource: "$sql"},
+				{RefID: "E", Expr: `{job="x"}`, Datasource: "Loki"},
+				{RefID: "F", Expr: "SELECT mean(v) FROM cpu", Datasource: "Metrics DB"},
+			},
+		}},
+	}
+	rules := DefaultRules()
+	rules.Datasources = map[string]string{"sql": "mysql"}
+	sd, report := Convert(gd, &rules)
+
+	w := sd.Widgets[0]
+	var names []string
+	for _, q := range w.Query.Builder.QueryData {
+		names = append(names, q.QueryName)
+	}
+	if got := strings.Join(names, ","); got != "A,C" {
+		t.Fatalf("converted queries = %s", got)
+	}
+	if len(w.Query.GrafanaExprs) != 6 {
+		t.Fatalf("grafana exprs = %v", w.Query.GrafanaExprs)
+	}
+	if len(w.Warnings) != 4 || !strings.Contains(w.Warnings[0], "B: loki") || !strings.Contains(w.Warnings[1], "D: mysql") ||
+		!strings.Contains(w.Warnings[2], "E: loki") || !strings.Contains(w.Warnings[3], "F: datasource could not be resolved") {
+		t.Fatalf("warnings = %v", w.Warnings)
+	}
+	var msgs []string
+	for _, it := range report.Items {
+		msgs = append(msgs, it.Message)
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{"$DS_OTHER could not be resolved", `datasource "Metrics DB" could not be resolved`} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("report missing %q:\n%s", want, all)
+		}
+	}
+	if len(sd.Variables) != 1 {
+		t.Fatalf("datasource variable emitted: %v", sd.Variables)
+	}
+}
+
+func TestUntranslatedFields(t *testing.T) {
+	const in = `{"panels": [{"id": 1, "type": "timeseries", "gridPos": {"h": 8, "w": 12, "x": 0, "y": 0}, "maxDataPoints": 100,
+  "targets": [{"refId": "A", "expr": "up", "interval": "$__interval", "editorMode": "code", "step": 30},
+              {"refId": "B", "expr": "down"}]}]}`
+	gd, err := parser.ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	sd := GrafanaToSigNoz(gd, nil)
+	b, err := json.Marshal(sd.Widgets[0].Untranslated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != `{"panel":{"maxDataPoints":100},"targets":{"A":{"interval":"$__interval","step":30}}}` {
+		t.Fatalf("untranslated = %s", got)
+	}
+}
+
+func TestHiddenAndInstantTargets(t *testing.T) {
+	targets := []parser.GrafanaTarget{
+		{RefID: "A", Expr: "up", Hide: true},
+		{RefID: "B", Expr: "sum(rate(x_total[5m]))", Instant: true},
+		{RefID: "C", Expr: "down", QueryType: "instant"},
+	}
+	calcs := json.RawMessage(`{"reduceOptions": {"calcs": ["mean"]}}`)
+	gd := &parser.GrafanaDashboard{Panels: []parser.GrafanaPanel{
+		{ID: 1, Type: "stat", Options: calcs, Targets: targets, GridPos: &parser.GrafanaGridPos{W: 12, H: 8}},
+		{ID: 2, Type: "timeseries", Targets: targets, GridPos: &parser.GrafanaGridPos{Y: 8, W: 12, H: 8}},
+	}}
+	sd := GrafanaToSigNoz(gd, nil)
+
+	stat := sd.Widgets[0]
+	var got []string
+	for i, q := range stat.Query.Builder.QueryData {
+		got = append(got, fmt.Sprintf("%s:%v:%s:%v", q.QueryName, q.Disabled, q.ReduceTo, stat.Query.PromQL[i].Disabled))
+	}
+	if s := strings.Join(got, " "); s != "A:true:avg:true B:false:last:false C:false:last:false" {
+		t.Fatalf("stat queries = %s", s)
+	}
+	if len(stat.Warnings) != 0 {
+		t.Fatalf("stat warnings = %v", stat.Warnings)
+	}
+	if ws := sd.Widgets[1].Warnings; len(ws) != 2 || !strings.Contains(ws[0], "target B is an instant query") {
+		t.Fatalf("graph warnings = %v", ws)
+	}
+}
+
+func TestQueryVariableSQL(t *testing.T) {
+	vars := map[string]parser.GrafanaVariable{
+		"cluster": {Name: "cluster"},
+		"node":    {Name: "node", Multi: true},
+	}
+	const from = " FROM signoz_metrics.distributed_time_series_v4_1day"
+	cases := []struct{ in, want string }{
+		{`label_values(k8s_node_cpu_time, k8s_cluster_name)`,
+			"SELECT JSONExtractString(labels, 'k8s_cluster_name') AS `k8s_cluster_name`" + from + " WHERE metric_name = 'k8s_node_cpu_time' GROUP BY `k8s_cluster_name`"},
+		{`label_values(k8s_node_cpu_time{k8s_cluster_name="$cluster", k8s_node_name=~"$node", env!="dev"}, pod)`,
+			"SELECT JSONExtractString(labels, 'pod') AS `pod`" + from + " WHERE metric_name = 'k8s_node_cpu_time' AND JSONExtractString(labels, 'k8s_cluster_name') = $cluster AND JSONExtractString(labels, 'k8s_node_name') IN $node AND JSONExtractString(labels, 'env') != 'dev' GROUP BY `pod`"},
+		{`label_values({job=~"api|web"}, instance)`,
+			"SELECT JSONExtractString(labels, 'instance') AS `instance`" + from + " WHERE match(JSONExtractString(labels, 'job'), '^(?:api|web)$') GROUP BY `instance`"},
+		{`label_values(job)`,
+			"SELECT JSONExtractString(labels, 'job') AS `job`" + from + " WHERE JSONExtractString(labels, 'job') != '' GROUP BY `job`"},
+		{`label_names()`,
+			"SELECT arrayJoin(JSONExtractKeys(labels)) AS label" + from + " GROUP BY label HAVING label != '__name__'"},
+		{`metrics(node_.*)`,
+			"SELECT metric_name" + from + " WHERE match(metric_name, 'node_.*') GROUP BY metric_name"},
+		{`query_result(sum by (namespace) (kube_pod_info{cluster="$cluster"}))`,
+			"SELECT JSONExtractString(labels, 'namespace') AS `namespace`" + from + " WHERE metric_name = 'kube_pod_info' AND JSONExtractString(labels, 'cluster') = $cluster GROUP BY `namespace`"},
+		{`query_result(sum by (pod) (up{job=~"a\\.b"}))`,
+			"SELECT JSONExtractString(labels, 'pod') AS `pod`" + from + " WHERE metric_name = 'up' AND match(JSONExtractString(labels, 'job'), '^(?:a\\\\.b)$') GROUP BY `pod`"},
+	}
+	for _, c := range cases {
+		got, err := variableSQL(c.in, "", vars)
+		if err != nil {
+			t.Fatalf("%s: %v", c.in, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s:\n got %s\nwant %s", c.in, got, c.want)
+		}
+	}
+	for _, in := range []string{`query_result(up)`, `label_values(up{job="x-$env"}, pod)`, `up`} {
+		if got, err := variableSQL(in, "", vars); err == nil {
+			t.Fatalf("%s: expected an error, got %s", in, got)
+		}
+	}
+}
+
+func TestVariableTypes(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		Templating: parser.GrafanaTemplate{List: []parser.GrafanaVariable{
+			{Name: "env", Type: "custom", Query: "prod : production, staging,dev"},
+			{Name: "size", Type: "custom", Query: "ignored", Options: []parser.GrafanaVariableOption{{Value: "$__all"}, {Value: "s"}, {Value: "m"}}},
+			{Name: "filter", Type: "textbox", Query: "default", Current: map[string]interface{}{"value": "api"}},
+			{Name: "job", Type: "constant", Query: "node"},
+			{Name: "step", Type: "interval", Query: "auto,2m,5m", Current: map[string]interface{}{"value": "$__auto_interval_step"}},
+			{Name: "ds", Type: "datasource", Query: "prometheus"},
+			{Name: "filters", Type: "adhoc"},
+			{Name: "inst", Type: "query", Query: `label_values(up{job="$job"}, instance)`},
+		}},
+		Panels: []parser.GrafanaPanel{{
+			ID: 1, Type: "timeseries", Title: "$job rate", GridPos: &parser.GrafanaGridPos{W: 12, H: 8},
+			Targets: []parser.GrafanaTarget{
+				{RefID: "A", Expr: `rate(http_requests_total{job="$job"}[$step])`},
+				{RefID: "B", Expr: `rate(http_errors_total{job="${job}"}[$step])`, Interval: "30s"},
+			},
+		}},
+	}
+	sd, report := Convert(gd, nil)
+
+	byName := map[string]map[string]interface{}{}
+	for _, v := range sd.Variables {
+		m := v.(map[string]interface{})
+		byName[m["name"].(string)] = m
+	}
+	if len(byName) != 4 {
+		t.Fatalf("variables = %v", byName)
+	}
+	if v := byName["env"]; v["type"] != "CUSTOM" || v["customValue"] != "production,staging,dev" {
+		t.Fatalf("env = %v", v)
+	}
+	if v := byName["size"]; v["customValue"] != "s,m" {
+		t.Fatalf("size = %v", v)
+	}
+	if v := byName["filter"]; v["type"] != "TEXTBOX" || v["textboxValue"] != "api" {
+		t.Fatalf("filter = %v", v)
+	}
+	if q := byName["inst"]["queryValue"].(string); !strings.Contains(q, "JSONExtractString(labels, 'job') = 'node'") {
+		t.Fatalf("inst query = %s", q)
+	}
+
+	w := sd.Widgets[0]
+	if w.Title != "node rate" {
+		t.Fatalf("title = %q", w.Title)
+	}
+	a, b := w.Query.Builder.QueryData[0], w.Query.Builder.QueryData[1]
+	if a.StepInterval != 120 || b.StepInterval != 30 {
+		t.Fatalf("steps = %d, %d", a.StepInterval, b.StepInterval)
+	}
+	if w.Query.PromQL[0].Query != `rate(http_requests_total{job="node"}[2m])` || a.Filters.Items[0].Value != "node" {
+		t.Fatalf("query A = %s, filters %+v", w.Query.PromQL[0].Query, a.Filters.Items)
+	}
+	if len(report.Items) != 1 || !strings.Contains(report.Items[0].Message, "$filters: ad hoc filters") {
+		t.Fatalf("report = %+v", report.Items)
+	}
+}
+
+func TestVariableState(t *testing.T) {
+	gd := &parser.GrafanaDashboard{Templating: parser.GrafanaTemplate{List: []parser.GrafanaVariable{
+		{Name: "job", Type: "query", Query: "label_values(up, job)", Sort: 2, Regex: "/^api-.*/",
+			Current: map[string]interface{}{"text": "api-1", "value": "api-1"}},
+		{Name: "pod", Type: "query", Query: "label_values(kube_pod_info, pod)", Sort: 3, Multi: true, IncludeAll: true, Hide: 2,
+			Regex:   `/pod-(\d+)/`,
+			Current: map[string]interface{}{"text": []interface{}{"All"}, "value": []interface{}{"$__all"}},
+			Options: []parser.GrafanaVariableOption{{Value: "$__all"}, {Value: "pod-1"}, {Value: "pod-2"}}},
+		{Name: "ns", Type: "query", Query: `query_result(kube_namespace_labels{cluster="c1"})`, Regex: `/.*namespace="([^"]+)".*/`,
+			Options: []parser.GrafanaVariableOption{{Value: "a"}, {Value: "b", Selected: true}}},
+	}}}
+	sd, report := Convert(gd, nil)
+	byName := map[string]map[string]interface{}{}
+	for _, v := range sd.Variables {
+		m := v.(map[string]interface{})
+		byName[m["name"].(string)] = m
+	}
+	const from = " FROM signoz_metrics.distributed_time_series_v4_1day"
+
+	job := byName["job"]
+	if job["sort"] != "DESC" || job["selectedValue"] != "api-1" || job["allSelected"] != false {
+		t.Fatalf("job = %v", job)
+	}
+	if q := job["queryValue"]; q != "SELECT JSONExtractString(labels, 'job') AS `job`"+from+" WHERE metric_name = 'up' GROUP BY `job` HAVING match(`job`, '^api-.*')" {
+		t.Fatalf("job query = %s", q)
+	}
+	pod := byName["pod"]
+	if pod["sort"] != "ASC" || pod["allSelected"] != true || fmt.Sprint(pod["selectedValue"]) != "[pod-1 pod-2]" {
+		t.Fatalf("pod = %v", pod)
+	}
+	if q := pod["queryValue"]; q != `SELECT extract(JSONExtractString(labels, 'pod'), 'pod-(\\d+)') AS `+"`pod`"+from+" WHERE metric_name = 'kube_pod_info' GROUP BY `pod` HAVING `pod` != ''" {
+		t.Fatalf("pod query = %s", q)
+	}
+	ns := byName["ns"]
+	if ns["selectedValue"] != "b" || ns["sort"] != "DISABLED" {
+		t.Fatalf("ns = %v", ns)
+	}
+	if q := ns["queryValue"]; q != "SELECT JSONExtractString(labels, 'namespace') AS `namespace`"+from+" WHERE metric_name = 'kube_namespace_labels' AND JSONExtractString(labels, 'cluster') = 'c1' GROUP BY `namespace`" {
+		t.Fatalf("ns query = %s", q)
+	}
+	if len(report.Items) != 1 || !strings.Contains(report.Items[0].Message, "$pod is hidden") {
+		t.Fatalf("report = %+v", report.Items)
+	}
+}
+
+func TestDeterministicIDs(t *testing.T) {
+	convert := func() []byte {
+		gd, err := parser.ParseGrafanaDashboardFile("../../testdata/grafana-dasboards/node-application.json")
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		rules := DefaultRules()
+		rules.IDMode = IDDeterministic
+		b, err := json.Marshal(GrafanaToSigNoz(gd, &rules))
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return b
+	}
+	first, second := convert(), convert()
+	if string(first) != string(second) {
+		t.Fatalf("deterministic conversions differ")
+	}
+
+	gd := &parser.GrafanaDashboard{UID: "abc", Panels: []parser.GrafanaPanel{{ID: 7, Type: "graph", Targets: []parser.GrafanaTarget{
+		{RefID: "A", Expr: `sum by (b, a) (rate(x_total{job="$job"}[5m]))`, LegendFormat: "{{c}} {{a}}"},
+	}}}}
+	rules := DefaultRules()
+	rules.IDMode = IDDeterministic
+	w := GrafanaToSigNoz(gd, &rules).Widgets[0]
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(w.ID) {
+		t.Fatalf("widget id %q is not a v5 UUID", w.ID)
+	}
+	var keys []string
+	for _, g := range w.Query.Builder.QueryData[0].GroupBy {
+		keys = append(keys, g.Key)
+	}
+	if got := strings.Join(keys, ","); got != "b,a,c" {
+		t.Fatalf("groupBy = %s", got)
+	}
+	gd.UID = "other"
+	if GrafanaToSigNoz(gd, &rules).Widgets[0].ID == w.ID {
+		t.Fatalf("widget ids do not depend on the dashboard uid")
+	}
+}
+
+func TestDeterministicIDsWithoutPanelIDs(t *testing.T) {
+	gd := &parser.GrafanaDashboard{UID: "noids", Panels: []parser.GrafanaPanel{
+		{Type: "timeseries", Title: "A", Targets: []parser.GrafanaTarget{{RefID: "A", Expr: `up{job="a"}`}}},
+		{Type: "timeseries", Title: "B", Targets: []parser.GrafanaTarget{{RefID: "A", Expr: `up{job="b"}`}}},
+	}}
+	rules := DefaultRules()
+	rules.IDMode = IDDeterministic
+	sd := GrafanaToSigNoz(gd, &rules)
+	a, b := sd.Widgets[0], sd.Widgets[1]
+	if a.ID == b.ID || a.Query.ID == b.Query.ID ||
+		a.Query.Builder.QueryData[0].Filters.Items[0].ID == b.Query.Builder.QueryData[0].Filters.Items[0].ID {
+		t.Fatalf("panels without ids share ids: %s %s", a.ID, b.ID)
+	}
+	if again := GrafanaToSigNoz(gd, &rules); again.Widgets[0].ID != a.ID || again.Widgets[1].ID != b.ID {
+		t.Fatalf("ids are not deterministic")
+	}
+}
+
+func TestVariableOrder(t *testing.T) {
+	gd := &parser.GrafanaDashboard{Templating: parser.GrafanaTemplate{List: []parser.GrafanaVariable{
+		{Name: "pod", Type: "query", Query: `label_values(kube_pod_info{namespace="$namespace", cluster="${cluster}"}, pod)`},
+		{Name: "ds", Type: "datasource", Query: "prometheus"},
+		{Name: "namespace", Type: "query", Query: `label_values(kube_namespace_labels{cluster="[[cluster]]"}, namespace)`},
+		{Name: "cluster", Type: "query", Query: "label_values(up{env=\"$env\"}, cluster)"},
+		{Name: "env", Type: "constant", Query: "prod"},
+		{Name: "a", Type: "query", Query: `label_values(x{b="$b"}, a)`},
+		{Name: "b", Type: "query", Query: `label_values(x{a="$a"}, b)`},
+		{Name: "node", Type: "query", Query: `label_values(node_uname_info{ds="$ds", f="$filters"}, nodename)`, Regex: "/$__all/"},
+		{Name: "filters", Type: "adhoc"},
+	}}}
+	sd, report := Convert(gd, nil)
+	order := map[string]int{}
+	for _, v := range sd.Variables {
+		m := v.(map[string]interface{})
+		order[m["name"].(string)] = m["order"].(int)
+	}
+	want := map[string]int{"cluster": 0, "namespace": 1, "pod": 2, "b": 3, "a": 4, "node": 5}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	var msgs []string
+	for _, it := range report.Items {
+		msgs = append(msgs, it.Message)
+	}
+	got := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		"dependency cycle ($a → $b → $a)",
+		"$node references $ds (datasource variable)",
+		"$node references $filters (adhoc variable)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("report missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "$env") {
+		t.Fatalf("inlined constant reported:\n%s", got)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	gd := &parser.GrafanaDashboard{
+		UID: "a",
+		Links: []parser.GrafanaLink{
+			{Type: "link", Title: "Runbook", URL: "https://wiki/runbook", IncludeVars: true},
+			{Type: "dashboards", Title: "Related", Tags: []string{"k8s"}},
+		},
+		Panels: []parser.GrafanaPanel{{ID: 1, Type: "timeseries", Title: "CPU",
+			Links:    []parser.GrafanaLink{{Title: "Pods", URL: "https://grafana/sub/d/pods/k8s-pods?var-ns=$ns&${__url_time_range}"}},
+			FieldCfg: []byte(`{"defaults":{"links":[{"title":"Pod","url":"/d/other?pod=${__field.labels.pod}&x=${__data.fields[\"job\"]}&v=${__value.raw}","keepTime":true}]}}`),
+			Targets:  []parser.GrafanaTarget{{RefID: "A", Expr: "up"}},
+		}},
+	}
+	rules := DefaultRules()
+	rules.DashboardIDs = map[string]string{"pods": "p-1", "a": "a-1"}
+	sd, report := Convert(gd, &rules)
+	if sd.UUID != "a-1" {
+		t.Fatalf("uuid = %q", sd.UUID)
+	}
+	var got []string
+	for _, l := range sd.Widgets[0].ContextLinks.LinksData {
+		if l.ID == "" {
+			t.Fatalf("link without id: %+v", l)
+		}
+		got = append(got, l.Label+" "+l.URL)
+	}
+	want := []string{
+		"Pods /dashboard/p-1?var-ns={{ns}}&startTime={{_timestamp_start}}&endTime={{_timestamp_end}}",
+		"Pod /d/other?pod={{pod}}&x={{job}}&v=${__value.raw}&startTime={{_timestamp_start}}&endTime={{_timestamp_end}}",
+		"Runbook https://wiki/runbook",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("links =\n%s", strings.Join(got, "\n"))
+	}
+	var msgs []string
+	for _, it := range report.Items {
+		msgs = append(msgs, it.Message)
+	}
+	all := strings.Join(msgs, "\n")
+	for _, w := range []string{
+		`dashboard link "Related" lists the dashboards tagged k8s`,
+		`link "Runbook": ${__all_variables} has no SigNoz equivalent`,
+		`link "Pod" points to Grafana dashboard other`,
+		`link "Pod": ${__value.raw} has no SigNoz equivalent`,
+	} {
+		if !strings.Contains(all, w) {
+			t.Fatalf("report missing %q:\n%s", w, all)
+		}
+	}
+}
+
+func TestTimeSettings(t *testing.T) {
+	in := `{"uid":"t","time":{"from":"now-24h","to":"now"},"refresh":"30s","timezone":"utc",
+		"timepicker":{"hidden":true},
+		"panels":[
+			{"id":1,"type":"timeseries","timeFrom":"1h","timeShift":"1d","targets":[{"refId":"A","expr":"rate(x_total[5m])"}]},
+			{"id":2,"type":"stat","timeFrom":"2h","hideTimeOverride":true,"targets":[{"refId":"A","expr":"up"}]},
+			{"id":3,"type":"stat","timeFrom":"now/d","targets":[{"refId":"A","expr":"up"}]}
+		]}`
+	gd, err := parser.ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	sd, report := Convert(gd, nil)
+	src := sd.Grafana
+	if src == nil || src.Time == nil || src.Time.From != "now-24h" || src.Refresh != "30s" || src.Timezone != "utc" {
+		t.Fatalf("_grafana = %+v", src)
+	}
+	w1, w2, w3 := sd.Widgets[0], sd.Widgets[1], sd.Widgets[2]
+	if w1.TimePreference != "LAST_1_HR" || w2.TimePreference != "LAST_6_HR" || w3.TimePreference != "GLOBAL_TIME" {
+		t.Fatalf("time preferences = %s, %s, %s", w1.TimePreference, w2.TimePreference, w3.TimePreference)
+	}
+	fns := w1.Query.Builder.QueryData[0].Functions
+	if len(fns) != 1 || fns[0].Name != "offset" || fns[0].Args["duration"] != "1d" {
+		t.Fatalf("functions = %+v", fns)
+	}
+	if w1.Untranslated != nil {
+		t.Fatalf("untranslated = %+v", w1.Untranslated)
+	}
+	var msgs []string
+	for _, it := range report.Items {
+		msgs = append(msgs, it.Message)
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		`dashboard timezone "utc" is not converted`,
+		"time picker is hidden",
+		`relative time "2h" is approximated by LAST_6_HR`,
+		`relative time "now/d" is not supported`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("report missing %q:\n%s", want, all)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	in := `{"uid":"an","annotations":{"list":[
+		{"builtIn":1,"datasource":"-- Grafana --","enable":true,"name":"Annotations & Alerts","type":"dashboard"},
+		{"name":"Deploys","enable":true,"datasource":{"type":"prometheus","uid":"p"},
+		 "expr":"changes(kube_deployment_status_observed_generation{namespace=\"$ns\"}[1m]) > 0","step":"1m","titleFormat":"{{deployment}}"},
+		{"name":"Errors","enable":true,"datasource":"Loki","expr":"{app=\"api\"} |= \"error\""},
+		{"name":"Restarts","enable":false,"datasource":{"type":"prometheus","uid":"p"},"expr":"changes(kube_pod_container_status_restarts_total[5m]) > 0"},
+		{"name":"Custom","enable":true,"datasource":"Events DB","expr":"select 1"},
+		{"name":"Releases","enable":true,"datasource":{"type":"grafana","uid":"-- Grafana --"},"target":{"type":"tags","tags":["release"]}}
+	]},"templating":{"list":[{"name":"ns","type":"constant","query":"prod"}]},
+	"panels":[{"id":1,"type":"timeseries","gridPos":{"x":0,"y":0,"w":24,"h":8},"targets":[{"refId":"A","expr":"up"}]}]}`
+	gd, err := parser.ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	sd, report := Convert(gd, nil)
+	if len(sd.Widgets) != 2 {
+		t.Fatalf("widgets = %d", len(sd.Widgets))
+	}
+	w := sd.Widgets[1]
+	q := w.Query.PromQL[0]
+	if w.Title != "Annotation: Deploys" || w.PanelType != "bar" || w.GrafanaPanelID != 0 ||
+		q.Query != `changes(kube_deployment_status_observed_generation{namespace="prod"}[1m]) > 0` || q.Legend != "{{deployment}}" {
+		t.Fatalf("annotation widget = %+v", w)
+	}
+	if l := sd.Layout[1]; l.I != w.ID || l.Y < 8 {
+		t.Fatalf("annotation layout = %+v", l)
+	}
+	var msgs []string
+	for _, it := range report.Items {
+		msgs = append(msgs, it.Message)
+	}
+	all := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		`annotation "Deploys" is converted into an event widget`,
+		`annotation "Errors": loki query`,
+		`annotation "Restarts" is disabled in Grafana; not converted`,
+		`annotation "Custom": datasource could not be resolved`,
+		`annotation "Releases" shows Grafana annotations tagged release`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("report missing %q:\n%s", want, all)
+		}
+	}
+	if strings.Contains(all, "Annotations & Alerts") {
+		t.Fatalf("built-in annotation reported:\n%s", all)
+	}
+
+	rules := DefaultRules()
+	rules.Annotations = AnnotationsReport
+	if sd, _ := Convert(gd, &rules); len(sd.Widgets) != 1 {
+		t.Fatalf("report mode widgets = %d", len(sd.Widgets))
+	}
+}
+
+func TestTextPanels(t *testing.T) {
+	gd := &parser.GrafanaDashboard{Description: "Service overview", Panels: []parser.GrafanaPanel{
+		{ID: 1, Type: "text", Title: "Runbook", GridPos: &parser.GrafanaGridPos{W: 24, H: 4},
+			Options: []byte(`{"mode":"markdown","content":"# On call\n\nSee <a href=\"https://wiki/runbook\">the runbook</a>.<script>alert(1)</script> [x](javascript:alert(1)) [ok](https://ok)"}`)},
+		{ID: 2, Type: "text", Title: "Links", GridPos: &parser.GrafanaGridPos{Y: 4, W: 12, H: 4},
+			Mode: "html", Content: "<div style=\"x\">\n  <ul><li>Logs &amp; traces</li><li><a href=\"javascript:evil()\">bad</a></li></ul>\n</div><iframe src=\"https://x\"></iframe>&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{ID: 3, Type: "timeseries", Title: "CPU", GridPos: &parser.GrafanaGridPos{Y: 4, X: 12, W: 12, H: 4},
+			Targets: []parser.GrafanaTarget{{RefID: "A", Expr: "up"}}},
+	}}
+	// By default the content is shown in the dashboard description, and
+	// no widget is left without queries.
+	sd := GrafanaToSigNoz(gd, nil)
+	if len(sd.Widgets) != 1 || sd.Widgets[0].Title != "CPU" || len(sd.Widgets[0].Query.Builder.QueryData) != 1 || len(sd.Layout) != 1 {
+		t.Fatalf("widgets = %+v", sd.Widgets)
+	}
+	want := "Service overview\n\n### Runbook\n\n# On call\n\nSee [the runbook](https://wiki/runbook). x [ok](https://ok)\n\n### Links\n\n- Logs & traces\n- bad"
+	if sd.Description != want {
+		t.Fatalf("description = %q", sd.Description)
+	}
+
+	// Widget mode keeps query-less widgets, which SigNoz shows as "No
+	// Data"; the content is only in their description, which is reported.
+	rules := DefaultRules()
+	rules.TextPanels = TextWidget
+	sd, report := Convert(gd, &rules)
+	if len(sd.Widgets) != 3 {
+		t.Fatalf("widgets = %d", len(sd.Widgets))
+	}
+	for _, w := range sd.Widgets[:2] {
+		if w.PanelType != "value" || len(w.Query.Builder.QueryData) != 0 || len(w.Warnings) != 1 || !strings.Contains(w.Warnings[0], `"No Data"`) {
+			t.Fatalf("text widget = %+v", w)
+		}
+	}
+	if d := sd.Widgets[0].Description; d != "# On call\n\nSee [the runbook](https://wiki/runbook). x [ok](https://ok)" {
+		t.Fatalf("markdown description = %q", d)
+	}
+	if d := sd.Widgets[1].Description; d != "- Logs & traces\n- bad" {
+		t.Fatalf("html description = %q", d)
+	}
+	if len(report.Items) != 2 || report.Items[0].Title != "Runbook" {
+		t.Fatalf("report = %+v", report.Items)
+	}
+}
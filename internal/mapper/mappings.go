@@ -0,0 +1,225 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// Threshold is a SigNoz widget threshold. Labelled thresholds are how SigNoz
+// displays value-to-text mappings (e.g. 1 → UP in green).
+type Threshold struct {
+	Index                 string  `json:"index"`
+	KeyIndex              int     `json:"keyIndex"`
+	ThresholdOperator     string  `json:"thresholdOperator"`
+	ThresholdValue        float64 `json:"thresholdValue"`
+	ThresholdUnit         string  `json:"thresholdUnit"`
+	ThresholdColor        string  `json:"thresholdColor"`
+	ThresholdFormat       string  `json:"thresholdFormat"` // Text or Background
+	ThresholdLabel        string  `json:"thresholdLabel"`
+	ThresholdTableOptions string  `json:"thresholdTableOptions"`
+	IsEditEnabled         bool    `json:"isEditEnabled"`
+	SelectedGraph         string  `json:"selectedGraph"`
+}
+
+// valueRule is a mapping reduced to a single comparison SigNoz can express.
+type valueRule struct {
+	Op    string
+	Value float64
+	Text  string
+	Color string
+}
+
+// applyValueMappings converts fieldConfig.defaults.mappings and legacy
+// singlestat valueMaps/rangeMaps into labelled thresholds on value and table
+// widgets. Mappings SigNoz cannot express are recorded as widget warnings.
+func applyValueMappings(w *SigNozWidget, p parser.GrafanaPanel) {
+	fc := decodeFieldConfig(p.FieldCfg)
+	rules, warns := mappingRules(fc.Defaults.Mappings)
+	lr, lw := legacyMappingRules(p.ValueMaps, p.RangeMaps)
+	rules = append(rules, lr...)
+	warns = append(warns, lw...)
+	if len(rules) > 0 && w.PanelType != "value" && w.PanelType != "table" {
+		warns = append(warns, fmt.Sprintf("value mappings are not supported on %s widgets; %d mapping(s) dropped", w.PanelType, len(rules)))
+		rules = nil
+	}
+	w.Warnings = append(w.Warnings, warns...)
+
+	tableCol := ""
+	if w.PanelType == "table" && len(w.Query.Builder.QueryData) > 0 {
+		tableCol = w.Query.Builder.QueryData[0].QueryName
+	}
+	for _, r := range rules {
+		w.Thresholds = append(w.Thresholds, Threshold{
+			Index:                 newUUID(),
+			KeyIndex:              len(w.Thresholds),
+			ThresholdOperator:     r.Op,
+			ThresholdValue:        r.Value,
+			ThresholdUnit:         fc.Defaults.Unit,
+			ThresholdColor:        grafanaColor(r.Color),
+			ThresholdFormat:       "Text",
+			ThresholdLabel:        r.Text,
+			ThresholdTableOptions: tableCol,
+			IsEditEnabled:         false,
+			SelectedGraph:         w.PanelType,
+		})
+	}
+}
+
+// mappingRules translates Grafana 7+ fieldConfig mappings.
+func mappingRules(ms []valueMapping) ([]valueRule, []string) {
+	var out []valueRule
+	var warns []string
+	for _, m := range ms {
+		switch mappingType(m.Type) {
+		case "value":
+			if len(m.Options) == 0 { // Grafana 7
+				out, warns = appendValueRule(out, warns, m.Value, mappingResult{Text: m.Text})
+				continue
+			}
+			var opts map[string]mappingResult
+			if err := json.Unmarshal(m.Options, &opts); err != nil {
+				warns = append(warns, fmt.Sprintf("value mapping: %v", err))
+				continue
+			}
+			keys := make([]string, 0, len(opts))
+			for k := range opts {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				out, warns = appendValueRule(out, warns, k, opts[k])
+			}
+		case "range":
+			if len(m.Options) == 0 { // Grafana 7
+				out, warns = appendRangeRule(out, warns, m.From, m.To, mappingResult{Text: m.Text})
+				continue
+			}
+			var opts struct {
+				From   *float64      `json:"from"`
+				To     *float64      `json:"to"`
+				Result mappingResult `json:"result"`
+			}
+			if err := json.Unmarshal(m.Options, &opts); err != nil {
+				warns = append(warns, fmt.Sprintf("range mapping: %v", err))
+				continue
+			}
+			out, warns = appendRangeRule(out, warns, floatString(opts.From), floatString(opts.To), opts.Result)
+		case "regex":
+			var opts struct {
+				Pattern string        `json:"pattern"`
+				Result  mappingResult `json:"result"`
+			}
+			_ = json.Unmarshal(m.Options, &opts)
+			warns = append(warns, fmt.Sprintf("regex mapping %q → %q is not supported", opts.Pattern, opts.Result.Text))
+		case "special":
+			var opts struct {
+				Match  string        `json:"match"`
+				Result mappingResult `json:"result"`
+			}
+			_ = json.Unmarshal(m.Options, &opts)
+			warns = append(warns, fmt.Sprintf("special mapping %q → %q is not supported", opts.Match, opts.Result.Text))
+		default:
+			warns = append(warns, fmt.Sprintf("unknown mapping type %v", m.Type))
+		}
+	}
+	return out, warns
+}
+
+// legacyMappingRules translates singlestat valueMaps and rangeMaps.
+func legacyMappingRules(vms []parser.GrafanaValueMap, rms []parser.GrafanaRangeMap) ([]valueRule, []string) {
+	var out []valueRule
+	var warns []string
+	for _, vm := range vms {
+		if vm.Op != "" && vm.Op != "=" {
+			warns = append(warns, fmt.Sprintf("value map operator %q is not supported", vm.Op))
+			continue
+		}
+		out, warns = appendValueRule(out, warns, fmt.Sprint(vm.Value), mappingResult{Text: vm.Text})
+	}
+	for _, rm := range rms {
+		out, warns = appendRangeRule(out, warns, fmt.Sprint(rm.From), fmt.Sprint(rm.To), mappingResult{Text: rm.Text})
+	}
+	return out, warns
+}
+
+func appendValueRule(out []valueRule, warns []string, value string, res mappingResult) ([]valueRule, []string) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return out, append(warns, fmt.Sprintf("value mapping %q → %q is not numeric and not supported", value, res.Text))
+	}
+	return append(out, valueRule{Op: "=", Value: v, Text: res.Text, Color: res.Color}), warns
+}
+
+// appendRangeRule maps a range onto a single bound. SigNoz thresholds
+// compare against one value, so a range with both bounds keeps only its
+// lower bound and records a warning.
+func appendRangeRule(out []valueRule, warns []string, from, to string, res mappingResult) ([]valueRule, []string) {
+	f, ferr := strconv.ParseFloat(strings.TrimSpace(from), 64)
+	t, terr := strconv.ParseFloat(strings.TrimSpace(to), 64)
+	switch {
+	case ferr == nil && terr == nil:
+		warns = append(warns, fmt.Sprintf("range mapping %s..%s → %q: upper bound dropped", from, to, res.Text))
+		return append(out, valueRule{Op: ">=", Value: f, Text: res.Text, Color: res.Color}), warns
+	case ferr == nil:
+		return append(out, valueRule{Op: ">=", Value: f, Text: res.Text, Color: res.Color}), warns
+	case terr == nil:
+		return append(out, valueRule{Op: "<=", Value: t, Text: res.Text, Color: res.Color}), warns
+	default:
+		return out, append(warns, fmt.Sprintf("range mapping %q..%q → %q has no numeric bounds and is not supported", from, to, res.Text))
+	}
+}
+
+func mappingType(t interface{}) string {
+	switch v := t.(type) {
+	case string:
+		return strings.ToLower(v)
+	case float64:
+		switch v {
+		case 1:
+			return "value"
+		case 2:
+			return "range"
+		}
+	}
+	return ""
+}
+
+func floatString(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// grafanaColors maps Grafana's named palette colors to hex values.
+var grafanaColors = map[string]string{
+	"green":       "#73BF69",
+	"dark-green":  "#37872D",
+	"light-green": "#96D98D",
+	"red":         "#F2495C",
+	"dark-red":    "#C4162A",
+	"light-red":   "#FF7383",
+	"yellow":      "#FADE2A",
+	"dark-yellow": "#E0B400",
+	"orange":      "#FF9830",
+	"dark-orange": "#FA6400",
+	"blue":        "#5794F2",
+	"dark-blue":   "#1F60C4",
+	"purple":      "#B877D9",
+	"dark-purple": "#8F3BB8",
+	"text":        "#CCCCDC",
+	"transparent": "rgba(0, 0, 0, 0)",
+}
+
+// grafanaColor resolves a Grafana color name; hex and rgb values pass through.
+func grafanaColor(c string) string {
+	if hex, ok := grafanaColors[strings.ToLower(strings.TrimSpace(c))]; ok {
+		return hex
+	}
+	return c
+}
@@ -0,0 +1,83 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// GrafanaSource records where a converted dashboard came from. SigNoz has no
+// folders, so the Grafana folder is kept here and as a "folder:" tag.
+type GrafanaSource struct {
+	UID           string `json:"uid,omitempty"`
+	Version       int    `json:"version,omitempty"`
+	SchemaVersion int    `json:"schemaVersion,omitempty"`
+	FolderUID     string `json:"folderUid,omitempty"`
+	FolderTitle   string `json:"folderTitle,omitempty"`
+	URL           string `json:"url,omitempty"`
+	Updated       string `json:"updated,omitempty"`
+	UpdatedBy     string `json:"updatedBy,omitempty"`
+	// Default time range, refresh interval and timezone of the dashboard,
+	// which SigNoz dashboards do not store.
+	Time     *parser.GrafanaTimeRange `json:"time,omitempty"`
+	Refresh  string                   `json:"refresh,omitempty"`
+	Timezone string                   `json:"timezone,omitempty"`
+}
+
+// grafanaSource collects the dashboard's identity, time settings and API
+// metadata.
+func grafanaSource(g *parser.GrafanaDashboard) *GrafanaSource {
+	src := &GrafanaSource{UID: g.UID, Version: g.Version, SchemaVersion: g.SchemaVersion, Time: g.Time, Timezone: g.Timezone}
+	if refresh, ok := g.Refresh.(string); ok {
+		src.Refresh = refresh
+	}
+	if m := g.Meta; m != nil {
+		src.FolderUID = m.FolderUID
+		src.FolderTitle = m.FolderTitle
+		src.URL = m.URL
+		src.Updated = m.Updated
+		src.UpdatedBy = m.UpdatedBy
+		if src.Version == 0 {
+			src.Version = m.Version
+		}
+	}
+	if *src == (GrafanaSource{}) {
+		return nil
+	}
+	return src
+}
+
+// dashboardTags returns the migration tags followed by the Grafana tags and
+// the folder (outside the root "General" folder) as "folder:<title>".
+func dashboardTags(g *parser.GrafanaDashboard) []string {
+	tags := []string{"migrated", "grafana"}
+	seen := map[string]bool{"migrated": true, "grafana": true}
+	add := func(t string) {
+		t = strings.TrimSpace(t)
+		if t != "" && !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	for _, t := range g.Tags {
+		add(t)
+	}
+	if m := g.Meta; m != nil && m.FolderTitle != "" && m.FolderTitle != "General" {
+		add("folder:" + m.FolderTitle)
+	}
+	return tags
+}
+
+// reportRequires notes panel plugins from "__requires" that have no panel
+// type mapping and fall back to the default panel.
+func reportRequires(g *parser.GrafanaDashboard, rules *Rules, r *Report) {
+	for _, req := range g.Requires {
+		if req.Type != "panel" {
+			continue
+		}
+		if _, ok := rules.PanelTypeMap[strings.ToLower(req.ID)]; !ok {
+			r.add("", "", fmt.Sprintf("panel plugin %s (%s) has no mapping; its panels use %s", req.ID, req.Name, rules.DefaultPanel))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// panelOptions is the subset of a Grafana panel's options read by the mapper.
+type panelOptions struct {
+	ReduceOptions struct {
+		Calcs []string `json:"calcs"`
+	} `json:"reduceOptions"`
+}
+
+func decodePanelOptions(raw json.RawMessage) panelOptions {
+	var o panelOptions
+	if len(raw) == 0 {
+		return o
+	}
+	_ = json.Unmarshal(raw, &o)
+	return o
+}
+
+// reduceTo returns the SigNoz reduceTo for a Grafana panel reducer, read from
+// options.reduceOptions.calcs or the legacy singlestat valueName. It returns
+// "" when the panel specifies no reducer SigNoz can express.
+func reduceTo(p parser.GrafanaPanel) string {
+	for _, c := range decodePanelOptions(p.Options).ReduceOptions.Calcs {
+		if r := calcToReduceTo(c); r != "" {
+			return r
+		}
+	}
+	switch strings.ToLower(p.ValueName) {
+	case "avg":
+		return "avg"
+	case "current":
+		return "last"
+	case "max":
+		return "max"
+	case "min":
+		return "min"
+	case "total":
+		return "sum"
+	}
+	return ""
+}
+
+// calcToReduceTo maps a Grafana reducer id onto SigNoz reduceTo.
+func calcToReduceTo(c string) string {
+	switch strings.ToLower(c) {
+	case "mean":
+		return "avg"
+	case "last", "lastnotnull":
+		return "last"
+	case "max":
+		return "max"
+	case "min":
+		return "min"
+	case "sum":
+		return "sum"
+	}
+	return ""
+}
+
+// applyReduceTo sets reduceTo on every builder query of value, table and pie
+// widgets from the panel's reducer.
+func applyReduceTo(w *SigNozWidget, p parser.GrafanaPanel) {
+	switch w.PanelType {
+	case "value", "table", "pie":
+	default:
+		return
+	}
+	r := reduceTo(p)
+	if r == "" {
+		return
+	}
+	for i := range w.Query.Builder.QueryData {
+		w.Query.Builder.QueryData[i].ReduceTo = r
+	}
+}
+
+// isInstant reports whether a target evaluates at a single point in time.
+func isInstant(t parser.GrafanaTarget) bool {
+	return t.Instant || strings.EqualFold(t.QueryType, "instant")
+}
+
+// applyInstantTargets shows the latest value of instant targets on value
+// and table widgets, the closest SigNoz has to an instant query. Graphs
+// always plot a range, which is recorded as a warning.
+func applyInstantTargets(w *SigNozWidget, p parser.GrafanaPanel) {
+	for _, t := range p.Targets {
+		if !isInstant(t) {
+			continue
+		}
+		name := nonEmpty(t.RefID, "A")
+		switch w.PanelType {
+		case "value", "table":
+			for i := range w.Query.Builder.QueryData {
+				if w.Query.Builder.QueryData[i].QueryName == name {
+					w.Query.Builder.QueryData[i].ReduceTo = "last"
+				}
+			}
+		case "graph", "bar":
+			w.Warnings = append(w.Warnings, fmt.Sprintf("target %s is an instant query; SigNoz plots it over the time range", name))
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"grafana2signoz/internal/parser"
+)
+
+// applyOverrides evaluates fieldConfig.overrides against the widget's
+// queries. Display names become query legends, units become columnUnits,
+// fixed colors become customLegendColors and series hidden from the
+// visualization disable their query. Anything else is recorded as a warning.
+func applyOverrides(w *SigNozWidget, p parser.GrafanaPanel) {
+	fc := decodeFieldConfig(p.FieldCfg)
+	// Defaults apply to every series; overrides refine them per query.
+	if fc.Defaults.Unit != "" {
+		w.YAxisUnit = fc.Defaults.Unit
+	}
+	for _, o := range fc.Overrides {
+		matcher := o.Matcher.ID
+		opt := fmt.Sprint(o.Matcher.Options)
+		qs, err := matchQueries(w, matcher, opt)
+		if err != nil {
+			w.Warnings = append(w.Warnings, fmt.Sprintf("override %s(%s): %v", matcher, opt, err))
+			continue
+		}
+		all := len(qs) > 0 && len(qs) == len(w.Query.Builder.QueryData)
+		for _, prop := range o.Properties {
+			if !applyOverrideProperty(w, qs, all, matcher, opt, prop.ID, prop.Value) {
+				w.Warnings = append(w.Warnings, fmt.Sprintf("override %s(%s): property %q could not be converted", matcher, opt, prop.ID))
+			}
+		}
+	}
+}
+
+// matchQueries returns the indexes of builder queries selected by a Grafana
+// field matcher. Series are identified by refId and, when it is literal,
+// by legend.
+func matchQueries(w *SigNozWidget, matcher, opt string) ([]int, error) {
+	var out []int
+	qd := w.Query.Builder.QueryData
+	switch matcher {
+	case "byFrameRefID":
+		for i, q := range qd {
+			if q.QueryName == opt {
+				out = append(out, i)
+			}
+		}
+	case "byName":
+		for i, q := range qd {
+			if q.QueryName == opt || q.Legend == opt {
+				out = append(out, i)
+			}
+		}
+	case "byRegexp":
+		re, err := regexp.Compile(opt)
+		if err != nil {
+			return nil, err
+		}
+		for i, q := range qd {
+			if re.MatchString(q.QueryName) || (q.Legend != "" && re.MatchString(q.Legend)) {
+				out = append(out, i)
+			}
+		}
+	case "byType":
+		if opt == "number" {
+			for i := range qd {
+				out = append(out, i)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("matcher is not supported")
+	}
+	return out, nil
+}
+
+// applyOverrideProperty applies one override property to the matched
+// queries and reports whether it could be expressed in SigNoz.
+func applyOverrideProperty(w *SigNozWidget, qs []int, all bool, matcher, opt, id string, raw json.RawMessage) bool {
+	qd := w.Query.Builder.QueryData
+	switch id {
+	case "unit":
+		var unit string
+		if json.Unmarshal(raw, &unit) != nil || len(qs) == 0 {
+			return false
+		}
+		for _, i := range qs {
+			w.ColumnUnits[qd[i].QueryName] = unit
+		}
+		if all {
+			w.YAxisUnit = unit
+		}
+		return true
+	case "displayName":
+		var name string
+		if json.Unmarshal(raw, &name) != nil || len(qs) == 0 {
+			return false
+		}
+		for _, i := range qs {
+			setLegend(w, qd[i].QueryName, name)
+		}
+		return true
+	case "color":
+		var c struct {
+			Mode       string `json:"mode"`
+			FixedColor string `json:"fixedColor"`
+		}
+		if json.Unmarshal(raw, &c) != nil || c.Mode != "fixed" || c.FixedColor == "" {
+			return false
+		}
+		if w.CustomLegendColors == nil {
+			w.CustomLegendColors = map[string]string{}
+		}
+		color := grafanaColor(c.FixedColor)
+		if len(qs) == 0 {
+			// SigNoz keys custom colors by series label, which is what
+			// byName matches in Grafana.
+			if matcher != "byName" {
+				return false
+			}
+			w.CustomLegendColors[opt] = color
+			return true
+		}
+		for _, i := range qs {
+			w.CustomLegendColors[nonEmpty(qd[i].Legend, qd[i].QueryName)] = color
+		}
+		return true
+	case "custom.hideFrom":
+		var h struct {
+			Viz bool `json:"viz"`
+		}
+		if json.Unmarshal(raw, &h) != nil || !h.Viz || len(qs) == 0 {
+			return false
+		}
+		for _, i := range qs {
+			setDisabled(w, qd[i].QueryName)
+		}
+		return true
+	}
+	return false
+}
+
+// setLegend sets the legend of the named builder and PromQL queries.
+func setLegend(w *SigNozWidget, name, legend string) {
+	for i := range w.Query.Builder.QueryData {
+		if w.Query.Builder.QueryData[i].QueryName == name {
+			w.Query.Builder.QueryData[i].Legend = legend
+		}
+	}
+	for i := range w.Query.PromQL {
+		if w.Query.PromQL[i].Name == name {
+			w.Query.PromQL[i].Legend = legend
+		}
+	}
+}
+
+// setDisabled disables the named builder and PromQL queries.
+func setDisabled(w *SigNozWidget, name string) {
+	for i := range w.Query.Builder.QueryData {
+		if w.Query.Builder.QueryData[i].QueryName == name {
+			w.Query.Builder.QueryData[i].Disabled = true
+		}
+	}
+	for i := range w.Query.PromQL {
+		if w.Query.PromQL[i].Name == name {
+			w.Query.PromQL[i].Disabled = true
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package mapper
+
+// Typed SigNoz query structures. JSON tags follow the field names used by
+// SigNoz dashboard exports (see testdata/signoz-dashboards).
+
+// Query is the widget query envelope. QueryType selects which of the
+// builder, promql or clickhouse_sql sections SigNoz evaluates.
+type Query struct {
+	QueryType     string            `json:"queryType"`
+	Builder       Builder           `json:"builder"`
+	PromQL        []PromQLQuery     `json:"promql"`
+	ClickHouseSQL []ClickHouseQuery `json:"clickhouse_sql"`
+	ID            string            `json:"id"`
+	// GrafanaExprs preserves the original Grafana expressions for manual follow-up.
+	GrafanaExprs []string `json:"_grafanaExprs"`
+}
+
+// Builder holds the query builder queries and formulas combining them.
+type Builder struct {
+	QueryData     []BuilderQuery `json:"queryData"`
+	QueryFormulas []Formula      `json:"queryFormulas"`
+}
+
+// BuilderQuery is a single query builder entry (A, B, ...).
+type BuilderQuery struct {
+	AggregateAttribute AggregateAttribute `json:"aggregateAttribute"`
+	AggregateOperator  string             `json:"aggregateOperator"`
+	DataSource         string             `json:"dataSource"`
+	Disabled           bool               `json:"disabled"`
+	Expression         string             `json:"expression"`
+	Filters            FilterSet          `json:"filters"`
+	Functions          []Function         `json:"functions"`
+	GroupBy            []GroupByKey       `json:"groupBy"`
+	Having             []Having           `json:"having"`
+	Legend             string             `json:"legend"`
+	Limit              *int               `json:"limit"`
+	OrderBy            []OrderBy          `json:"orderBy"`
+	QueryName          string             `json:"queryName"`
+	ReduceTo           string             `json:"reduceTo"`
+	SpaceAggregation   string             `json:"spaceAggregation"`
+	StepInterval       int                `json:"stepInterval"`
+	TimeAggregation    string             `json:"timeAggregation"`
+}
+
+// AttributeKey identifies a metric, tag or resource attribute.
+type AttributeKey struct {
+	DataType string `json:"dataType"`
+	ID       string `json:"id"`
+	IsColumn bool   `json:"isColumn"`
+	IsJSON   bool   `json:"isJSON"`
+	Key      string `json:"key"`
+	Type     string `json:"type"`
+}
+
+// AggregateAttribute is the metric a builder query aggregates.
+type AggregateAttribute = AttributeKey
+
+// GroupByKey is an attribute a builder query groups by.
+type GroupByKey = AttributeKey
+
+// FilterSet combines filter items with a logical operator (AND).
+type FilterSet struct {
+	Items []FilterItem `json:"items"`
+	Op    string       `json:"op"`
+}
+
+// FilterItem is a single attribute filter. Value is a string or a list of
+// strings depending on the operator.
+type FilterItem struct {
+	ID    string       `json:"id"`
+	Key   AttributeKey `json:"key"`
+	Op    string       `json:"op"`
+	Value interface{}  `json:"value"`
+}
+
+// Having filters aggregated results, e.g. #SIGNOZ_VALUE > 0.
+type Having struct {
+	ColumnName string      `json:"columnName"`
+	Op         string      `json:"op"`
+	Value      interface{} `json:"value"`
+}
+
+// OrderBy sorts results by a column.
+type OrderBy struct {
+	ColumnName string `json:"columnName"`
+	Order      string `json:"order"`
+}
+
+// Function is a post-processing function applied to a builder query.
+type Function struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// Formula combines builder queries via an expression, e.g. A/B.
+type Formula struct {
+	Disabled   bool   `json:"disabled"`
+	Expression string `json:"expression"`
+	Legend     string `json:"legend"`
+	Limit      *int   `json:"limit,omitempty"`
+	QueryName  string `json:"queryName"`
+}
+
+// PromQLQuery is a raw PromQL query entry.
+type PromQLQuery struct {
+	Disabled bool   `json:"disabled"`
+	Legend   string `json:"legend"`
+	Name     string `json:"name"`
+	Query    string `json:"query"`
+}
+
+// ClickHouseQuery is a raw ClickHouse SQL query entry.
+type ClickHouseQuery struct {
+	Disabled bool   `json:"disabled"`
+	Legend   string `json:"legend"`
+	Name     string `json:"name"`
+	Query    string `json:"query"`
+}
@@ -0,0 +1,381 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// Repeat modes for Rules.RepeatMode.
+const (
+	// RepeatGroup keeps one widget per repeating panel and groups its
+	// queries by the label the repeat variable filters on.
+	RepeatGroup = "group"
+	// RepeatExpand creates one widget (or row) per known variable value.
+	RepeatExpand = "expand"
+)
+
+// expandRepeats rewrites repeating rows and panels. In expand mode every
+// repeat is copied once per value of its variable (the current selection,
+// or all options when All is selected) with the variable substituted, and
+// panels below are moved down to make room. Repeats whose values are
+// unknown, and all repeats in group mode, are left to applyRepeatGroup;
+// members of repeating rows inherit the row's repeat variable for that.
+func expandRepeats(g *parser.GrafanaDashboard, mode string) ([]parser.GrafanaPanel, []string) {
+	panels := clonePanels(g.Panels)
+	var warns []string
+	nextID := 0
+	for _, p := range panels {
+		if p.ID >= nextID {
+			nextID = p.ID + 1
+		}
+	}
+	newID := func() int {
+		nextID++
+		return nextID - 1
+	}
+	nextKey := 0
+	for _, p := range panels {
+		if p.RowKey >= nextKey {
+			nextKey = p.RowKey + 1
+		}
+	}
+
+	// Rows first, so that repeating panels inside them are expanded per copy.
+	for i := 0; i < len(panels); i++ {
+		r := panels[i]
+		if r.Type != "row" || r.Repeat == "" {
+			continue
+		}
+		vals := variableValues(g, r.Repeat)
+		if mode != RepeatExpand || len(vals) == 0 {
+			if mode == RepeatExpand {
+				warns = append(warns, fmt.Sprintf("row %q: no known values for $%s; grouped instead of expanded", r.Title, r.Repeat))
+			}
+			for j := range panels {
+				if inRow(panels[j], r) && panels[j].Repeat == "" {
+					panels[j].Repeat = r.Repeat
+				}
+			}
+			panels[i].Repeat = ""
+			continue
+		}
+		skip := map[int]bool{i: true}
+		var members []parser.GrafanaPanel
+		for j, m := range panels {
+			if inRow(m, r) {
+				skip[j] = true
+				members = append(members, m)
+			}
+		}
+		height := sectionHeight(r, members)
+		for _, j := range panelsBelow(panels, 0, r, height, skip) {
+			shiftY(&panels[j], (len(vals)-1)*height)
+		}
+		var section []parser.GrafanaPanel
+		for k, v := range vals {
+			row := substitutePanel(r, r.Repeat, v)
+			row.Repeat = ""
+			if k > 0 {
+				row.ID, row.RowKey = newID(), nextKey
+				nextKey++
+				shiftY(&row, k*height)
+			}
+			section = append(section, row)
+			for _, m := range members {
+				c := substitutePanel(m, r.Repeat, v)
+				if k > 0 {
+					c.ID = newID()
+					c.RowID = row.RowKey
+					shiftY(&c, k*height)
+				}
+				section = append(section, c)
+			}
+		}
+		// Replace the row and its members with the expanded section.
+		rest := make([]parser.GrafanaPanel, 0, len(panels)+len(section))
+		rest = append(rest, panels[:i]...)
+		rest = append(rest, section...)
+		for j := i + 1; j < len(panels); j++ {
+			if !skip[j] {
+				rest = append(rest, panels[j])
+			}
+		}
+		panels = rest
+		i += len(section) - 1
+	}
+
+	for i := 0; i < len(panels); i++ {
+		p := panels[i]
+		if p.Type == "row" || p.Repeat == "" || mode != RepeatExpand {
+			continue
+		}
+		vals := variableValues(g, p.Repeat)
+		if len(vals) == 0 {
+			warns = append(warns, fmt.Sprintf("panel %q: no known values for $%s; grouped instead of expanded", p.Title, p.Repeat))
+			continue
+		}
+		copies, extra := repeatCopies(p, vals, newID)
+		if extra > 0 && p.GridPos != nil {
+			for _, j := range panelsBelow(panels, p.RowID, p, p.GridPos.H, map[int]bool{i: true}) {
+				shiftY(&panels[j], extra)
+			}
+		}
+		panels = append(panels[:i], append(copies, panels[i+1:]...)...)
+		i += len(copies) - 1
+	}
+	return panels, warns
+}
+
+// repeatCopies lays out one copy of p per value following Grafana's repeat
+// rules: horizontal repeats share the panel's row (at most maxPerRow, default
+// 4 and at most 24, per line); vertical repeats stack. It returns the copies and the height
+// they add below the original panel.
+func repeatCopies(p parser.GrafanaPanel, vals []string, newID func() int) ([]parser.GrafanaPanel, int) {
+	out := make([]parser.GrafanaPanel, 0, len(vals))
+	g := p.GridPos
+	width, perRow := 0, len(vals)
+	if g != nil && p.RepeatDirection != "v" {
+		maxPerRow := p.MaxPerRow
+		switch {
+		case maxPerRow <= 0:
+			maxPerRow = 4
+		case maxPerRow > grafanaCols:
+			maxPerRow = grafanaCols
+		}
+		width = grafanaCols / len(vals)
+		if w := grafanaCols / maxPerRow; w > width {
+			width = w
+		}
+		if width < 1 {
+			width = 1
+		}
+		perRow = grafanaCols / width
+	}
+	for k, v := range vals {
+		c := substitutePanel(p, p.Repeat, v)
+		c.Repeat = ""
+		if k > 0 {
+			c.ID = newID()
+		}
+		if g != nil {
+			pos := *g
+			if p.RepeatDirection == "v" {
+				pos.Y = g.Y + k*g.H
+			} else {
+				pos.X = (k % perRow) * width
+				pos.Y = g.Y + (k/perRow)*g.H
+				pos.W = width
+			}
+			c.GridPos = &pos
+		}
+		out = append(out, c)
+	}
+	if g == nil {
+		return out, 0
+	}
+	if p.RepeatDirection == "v" {
+		return out, (len(vals) - 1) * g.H
+	}
+	return out, ((len(vals)+perRow-1)/perRow - 1) * g.H
+}
+
+// sectionHeight is the grid height a row and its members occupy.
+func sectionHeight(r parser.GrafanaPanel, members []parser.GrafanaPanel) int {
+	if r.GridPos == nil {
+		return 1
+	}
+	h := 1
+	if r.Collapsed {
+		return h
+	}
+	for _, m := range members {
+		if m.GridPos != nil && m.GridPos.Y+m.GridPos.H-r.GridPos.Y > h {
+			h = m.GridPos.Y + m.GridPos.H - r.GridPos.Y
+		}
+	}
+	return h
+}
+
+// panelsBelow returns the indexes of panels sharing p's grid (the dashboard
+// grid, or the grid of p's collapsed row) that start at or below p.Y+height.
+// Indexes in skip are excluded.
+func panelsBelow(panels []parser.GrafanaPanel, rowID int, p parser.GrafanaPanel, height int, skip map[int]bool) []int {
+	if p.GridPos == nil {
+		return nil
+	}
+	collapsed := map[int]bool{}
+	for _, q := range panels {
+		if q.Type == "row" && q.Collapsed {
+			collapsed[q.RowKey] = true
+		}
+	}
+	space := func(q parser.GrafanaPanel) int {
+		if collapsed[q.RowID] {
+			return q.RowID
+		}
+		return 0
+	}
+	want := 0
+	if collapsed[rowID] {
+		want = rowID
+	}
+	var out []int
+	for j, q := range panels {
+		if skip[j] {
+			continue
+		}
+		if q.GridPos == nil || space(q) != want {
+			continue
+		}
+		if q.GridPos.Y >= p.GridPos.Y+height {
+			out = append(out, j)
+		}
+	}
+	return out
+}
+
+// inRow reports whether p is a member of row r.
+func inRow(p, r parser.GrafanaPanel) bool {
+	return p.RowID != 0 && p.RowID == r.RowKey
+}
+
+func shiftY(p *parser.GrafanaPanel, dy int) {
+	if p.GridPos == nil || dy == 0 {
+		return
+	}
+	pos := *p.GridPos
+	pos.Y += dy
+	p.GridPos = &pos
+}
+
+// clonePanels copies panels so that grid positions and targets can be
+// rewritten without touching the parsed dashboard.
+func clonePanels(ps []parser.GrafanaPanel) []parser.GrafanaPanel {
+	out := make([]parser.GrafanaPanel, len(ps))
+	for i, p := range ps {
+		if p.GridPos != nil {
+			pos := *p.GridPos
+			p.GridPos = &pos
+		}
+		p.Targets = append([]parser.GrafanaTarget(nil), p.Targets...)
+		out[i] = p
+	}
+	return out
+}
+
+// substitutePanel returns a copy of p with variable name replaced by value
+// in its title, target expressions and legends.
+func substitutePanel(p parser.GrafanaPanel, name, value string) parser.GrafanaPanel {
+	re := variableRefRegexp(name)
+	sub := func(s string) string {
+		return re.ReplaceAllLiteralString(s, value)
+	}
+	p.Title = sub(p.Title)
+	ts := make([]parser.GrafanaTarget, len(p.Targets))
+	for i, t := range p.Targets {
+		t.Expr = sub(t.Expr)
+		t.LegendFormat = sub(t.LegendFormat)
+		ts[i] = t
+	}
+	p.Targets = ts
+	return p
+}
+
+// variableRefRegexp matches the Grafana reference forms $name, ${name},
+// ${name:format} and [[name]].
+func variableRefRegexp(name string) *regexp.Regexp {
+	n := regexp.QuoteMeta(name)
+	return regexp.MustCompile(`\$\{` + n + `(?::[^}]*)?\}|\[\[` + n + `(?::[^\]]*)?\]\]|\$` + n + `\b`)
+}
+
+// variableValues returns the values a repeat over variable name expands to:
+// the current selection, or every option when All (or nothing) is selected.
+func variableValues(g *parser.GrafanaDashboard, name string) []string {
+	for _, v := range g.Templating.List {
+		if v.Name != name {
+			continue
+		}
+		cur := optionValues(v.Current)
+		if len(cur) > 0 && !contains(cur, "$__all") {
+			return cur
+		}
+		var all []string
+		for _, o := range v.Options {
+			for _, val := range stringValues(o.Value) {
+				if val != "$__all" {
+					all = append(all, val)
+				}
+			}
+		}
+		if len(all) == 0 && strings.EqualFold(v.Type, "custom") {
+			if q, ok := v.Query.(string); ok {
+				for _, val := range splitCSV(q) {
+					if val != "" {
+						all = append(all, val)
+					}
+				}
+			}
+		}
+		return all
+	}
+	return nil
+}
+
+// optionValues extracts the value(s) of a Grafana current/option object.
+func optionValues(cur interface{}) []string {
+	m, ok := cur.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return stringValues(m["value"])
+}
+
+func stringValues(v interface{}) []string {
+	switch x := v.(type) {
+	case string:
+		if x == "" {
+			return nil
+		}
+		return []string{x}
+	case []interface{}:
+		var out []string
+		for _, e := range x {
+			if s, ok := e.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// applyRepeatGroup collapses a repeating panel into one widget by grouping
+// its queries by every label filtered on the repeat variable.
+func applyRepeatGroup(w *SigNozWidget, p parser.GrafanaPanel) {
+	if p.Repeat == "" {
+		return
+	}
+	ref := "{{." + p.Repeat + "}}"
+	grouped := false
+	for i := range w.Query.Builder.QueryData {
+		q := &w.Query.Builder.QueryData[i]
+		for _, f := range q.Filters.Items {
+			if s, ok := f.Value.(string); !ok || !strings.Contains(s, ref) {
+				continue
+			}
+			grouped = true
+			if !hasGroupBy(*q, f.Key.Key) {
+				q.GroupBy = append(q.GroupBy, tagKey(f.Key.Key))
+			}
+			if q.Legend == "" {
+				setLegend(w, q.QueryName, "{{"+f.Key.Key+"}}")
+			}
+		}
+	}
+	if !grouped && len(w.Query.Builder.QueryData) > 0 {
+		w.Warnings = append(w.Warnings, fmt.Sprintf("repeat over $%s: no query filters on it; repeat dropped", p.Repeat))
+	}
+}
@@ -0,0 +1,55 @@
+package mapper
+
+import (
+	"fmt"
+
+	"grafana2signoz/internal/parser"
+)
+
+// Report lists Grafana settings that were dropped or approximated during a
+// conversion so they can be followed up manually in SigNoz.
+type Report struct {
+	Dashboard string       `json:"dashboard"`
+	Items     []ReportItem `json:"items"`
+}
+
+// ReportItem is a single conversion note. Widget is empty for
+// dashboard-level notes.
+type ReportItem struct {
+	Widget  string `json:"widget,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+func (r *Report) add(widget, title, msg string) {
+	r.Items = append(r.Items, ReportItem{Widget: widget, Title: title, Message: msg})
+}
+
+// addWidgetWarnings copies the warnings recorded on widgets into the report.
+func (r *Report) addWidgetWarnings(ws []SigNozWidget) {
+	for _, w := range ws {
+		for _, msg := range w.Warnings {
+			r.add(w.ID, w.Title, msg)
+		}
+	}
+}
+
+// AddMissingLibraryPanels reports library panel references that could not
+// be resolved, as returned by parser.ResolveLibraryPanels. References the
+// conversion already reported on their widget are not repeated.
+func (r *Report) AddMissingLibraryPanels(refs []parser.GrafanaLibraryPanelRef) {
+	seen := map[string]bool{}
+	for _, it := range r.Items {
+		seen[it.Message] = true
+	}
+	for _, ref := range refs {
+		if msg := missingLibraryPanel(ref); !seen[msg] {
+			seen[msg] = true
+			r.add("", "", msg)
+		}
+	}
+}
+
+func missingLibraryPanel(ref parser.GrafanaLibraryPanelRef) string {
+	return fmt.Sprintf("library panel %q (uid %s) not found; provide the library panel export to convert it", ref.Name, ref.UID)
+}
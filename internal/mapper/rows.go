@@ -0,0 +1,70 @@
+package mapper
+
+import (
+	"encoding/json"
+
+	"grafana2signoz/internal/parser"
+)
+
+// rowSection is a Grafana row and its member panels. The leading section
+// holds the panels above the first row and has no row.
+type rowSection struct {
+	row    *parser.GrafanaPanel
+	panels []parser.GrafanaPanel
+}
+
+// rowSections groups panels by the row they belong to (see parser RowID and
+// RowKey), keeping rows in dashboard order and members in grid order.
+func rowSections(panels []parser.GrafanaPanel) []rowSection {
+	top := rowSection{}
+	var rows []rowSection
+	members := map[int][]parser.GrafanaPanel{}
+	for i := range panels {
+		p := panels[i]
+		switch {
+		case p.Type == "row":
+			rows = append(rows, rowSection{row: &panels[i]})
+		case p.RowID != 0:
+			members[p.RowID] = append(members[p.RowID], p)
+		default:
+			top.panels = append(top.panels, p)
+		}
+	}
+	parser.SortByGridPos(top.panels)
+	out := make([]rowSection, 0, len(rows)+1)
+	if len(top.panels) > 0 {
+		out = append(out, top)
+	}
+	for _, r := range rows {
+		r.panels = members[r.row.RowKey]
+		parser.SortByGridPos(r.panels)
+		out = append(out, r)
+	}
+	return out
+}
+
+// rowWidget converts a Grafana row panel into a SigNoz row widget.
+func rowWidget(p parser.GrafanaPanel, ids *ids) SigNozWidget {
+	return SigNozWidget{
+		ID:             ids.widgetID(ids.panelKey(p)),
+		Title:          nonEmpty(p.Title, "Row"),
+		PanelType:      "row",
+		GrafanaPanelID: p.ID,
+	}
+}
+
+// MarshalJSON writes row widgets in the reduced form SigNoz uses for them;
+// other widgets are written as-is.
+func (w SigNozWidget) MarshalJSON() ([]byte, error) {
+	if w.PanelType == "row" {
+		return json.Marshal(struct {
+			ID          string `json:"id"`
+			PanelType   string `json:"panelTypes"`
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			PanelID     int    `json:"_grafanaPanelId,omitempty"`
+		}{w.ID, w.PanelType, w.Title, w.Description, w.GrafanaPanelID})
+	}
+	type widget SigNozWidget
+	return json.Marshal(widget(w))
+}
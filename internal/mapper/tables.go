@@ -0,0 +1,328 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// applyTableOptions shapes table widget queries after Grafana's table
+// semantics: one reduced value per group, column units from the panel unit,
+// and the panel's transformations translated into legends (column names),
+// disabled queries (hidden columns) and query/groupBy order (column order).
+// Transformations SigNoz cannot express are recorded as widget warnings.
+func applyTableOptions(w *SigNozWidget, p parser.GrafanaPanel) {
+	if w.PanelType != "table" {
+		for _, t := range p.Transformations {
+			if !t.Disabled {
+				w.Warnings = append(w.Warnings, fmt.Sprintf("transformation %q is only converted for table widgets", t.ID))
+			}
+		}
+		return
+	}
+
+	fc := decodeFieldConfig(p.FieldCfg)
+	for _, q := range w.Query.Builder.QueryData {
+		if _, ok := w.ColumnUnits[q.QueryName]; !ok && fc.Defaults.Unit != "" {
+			w.ColumnUnits[q.QueryName] = fc.Defaults.Unit
+		}
+	}
+	// Table-format targets show the latest value unless the panel says otherwise.
+	if reduceTo(p) == "" && hasTableFormat(p.Targets) {
+		setReduceTo(w, "last")
+	}
+
+	for _, t := range p.Transformations {
+		if t.Disabled {
+			continue
+		}
+		if err := applyTransformation(w, t); err != nil {
+			w.Warnings = append(w.Warnings, fmt.Sprintf("transformation %q: %v", t.ID, err))
+		}
+	}
+}
+
+func hasTableFormat(ts []parser.GrafanaTarget) bool {
+	for _, t := range ts {
+		if strings.EqualFold(t.Format, "table") {
+			return true
+		}
+	}
+	return false
+}
+
+func setReduceTo(w *SigNozWidget, r string) {
+	for i := range w.Query.Builder.QueryData {
+		w.Query.Builder.QueryData[i].ReduceTo = r
+	}
+}
+
+func applyTransformation(w *SigNozWidget, t parser.GrafanaTransformation) error {
+	switch t.ID {
+	case "organize":
+		var o struct {
+			ExcludeByName map[string]bool   `json:"excludeByName"`
+			IndexByName   map[string]int    `json:"indexByName"`
+			RenameByName  map[string]string `json:"renameByName"`
+		}
+		if err := decodeOptions(t.Options, &o); err != nil {
+			return err
+		}
+		// Grafana applies index, exclude and rename against the original names.
+		orderColumns(w, o.IndexByName)
+		var errs []string
+		for _, field := range sortedKeys(o.ExcludeByName) {
+			if !o.ExcludeByName[field] {
+				continue
+			}
+			if err := hideColumn(w, field); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		for _, field := range sortedKeys(o.RenameByName) {
+			if err := renameColumn(w, field, o.RenameByName[field]); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		return joinErrs(errs)
+	case "merge":
+		// SigNoz tables already join queries on their shared groupBy labels.
+		return nil
+	case "seriesToColumns", "joinByField":
+		var o struct {
+			ByField string `json:"byField"`
+		}
+		if err := decodeOptions(t.Options, &o); err != nil {
+			return err
+		}
+		if o.ByField == "" || strings.EqualFold(o.ByField, "Time") {
+			return fmt.Errorf("joining by time is not supported")
+		}
+		for i := range w.Query.Builder.QueryData {
+			q := &w.Query.Builder.QueryData[i]
+			if !hasGroupBy(*q, o.ByField) {
+				q.GroupBy = append(q.GroupBy, tagKey(o.ByField))
+			}
+		}
+		return nil
+	case "reduce":
+		var o struct {
+			Reducers []string `json:"reducers"`
+		}
+		if err := decodeOptions(t.Options, &o); err != nil {
+			return err
+		}
+		for _, r := range o.Reducers {
+			if rt := calcToReduceTo(r); rt != "" {
+				setReduceTo(w, rt)
+				if len(o.Reducers) > 1 {
+					return fmt.Errorf("only the first supported reducer (%s) is kept", r)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("reducers %v are not supported", o.Reducers)
+	case "filterFieldsByName":
+		var o struct {
+			Include *fieldNameFilter `json:"include"`
+			Exclude *fieldNameFilter `json:"exclude"`
+		}
+		if err := decodeOptions(t.Options, &o); err != nil {
+			return err
+		}
+		var errs []string
+		for _, col := range tableColumns(w) {
+			keep := true
+			if o.Include != nil {
+				keep = o.Include.matches(col)
+			}
+			if o.Exclude != nil && o.Exclude.matches(col) {
+				keep = false
+			}
+			if keep {
+				continue
+			}
+			if err := hideColumn(w, col); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		return joinErrs(errs)
+	case "renameByRegex":
+		var o struct {
+			Regex         string `json:"regex"`
+			RenamePattern string `json:"renamePattern"`
+		}
+		if err := decodeOptions(t.Options, &o); err != nil {
+			return err
+		}
+		re, err := regexp.Compile(o.Regex)
+		if err != nil {
+			return err
+		}
+		var errs []string
+		for _, col := range tableColumns(w) {
+			if !re.MatchString(col) {
+				continue
+			}
+			if err := renameColumn(w, col, re.ReplaceAllString(col, o.RenamePattern)); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		return joinErrs(errs)
+	}
+	return fmt.Errorf("not supported")
+}
+
+type fieldNameFilter struct {
+	Names   []string `json:"names"`
+	Pattern string   `json:"pattern"`
+}
+
+func (f *fieldNameFilter) matches(name string) bool {
+	if contains(f.Names, name) {
+		return true
+	}
+	if f.Pattern == "" {
+		return false
+	}
+	re, err := regexp.Compile(f.Pattern)
+	return err == nil && re.MatchString(name)
+}
+
+// tableColumns lists the Grafana column names of a table widget: the value
+// column of each query followed by the groupBy labels.
+func tableColumns(w *SigNozWidget) []string {
+	var out []string
+	seen := map[string]bool{}
+	qd := w.Query.Builder.QueryData
+	for _, q := range qd {
+		out = append(out, valueColumnName(q, len(qd)))
+	}
+	for _, q := range qd {
+		for _, g := range q.GroupBy {
+			if !seen[g.Key] {
+				seen[g.Key] = true
+				out = append(out, g.Key)
+			}
+		}
+	}
+	return out
+}
+
+// valueColumnName is the name Grafana gives a query's value column.
+func valueColumnName(q BuilderQuery, n int) string {
+	if q.Legend != "" && !looksLikeTemplate(q.Legend) {
+		return q.Legend
+	}
+	if n == 1 {
+		return "Value"
+	}
+	return "Value #" + q.QueryName
+}
+
+// columnQuery returns the index of the query whose value column is named
+// field, or -1.
+func columnQuery(w *SigNozWidget, field string) int {
+	qd := w.Query.Builder.QueryData
+	for i, q := range qd {
+		if field == valueColumnName(q, len(qd)) || field == "Value #"+q.QueryName || field == q.QueryName || (len(qd) == 1 && field == "Value") {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasGroupBy(q BuilderQuery, key string) bool {
+	for _, g := range q.GroupBy {
+		if g.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isGroupByLabel(w *SigNozWidget, key string) bool {
+	for _, q := range w.Query.Builder.QueryData {
+		if hasGroupBy(q, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// renameColumn renames a query value column via its legend. SigNoz always
+// shows label columns under the label name.
+func renameColumn(w *SigNozWidget, field, name string) error {
+	if i := columnQuery(w, field); i >= 0 {
+		setLegend(w, w.Query.Builder.QueryData[i].QueryName, name)
+		return nil
+	}
+	if isGroupByLabel(w, field) {
+		return fmt.Errorf("label column %q cannot be renamed to %q", field, name)
+	}
+	return nil
+}
+
+// hideColumn hides a query value column by disabling its query. Label
+// columns not in groupBy are never shown by SigNoz, so hiding them is a no-op.
+func hideColumn(w *SigNozWidget, field string) error {
+	if i := columnQuery(w, field); i >= 0 {
+		setDisabled(w, w.Query.Builder.QueryData[i].QueryName)
+		return nil
+	}
+	if isGroupByLabel(w, field) {
+		return fmt.Errorf("label column %q cannot be hidden", field)
+	}
+	return nil
+}
+
+// orderColumns reorders queries (value columns) and groupBy keys (label
+// columns) by Grafana's indexByName. Unindexed columns keep their order
+// after the indexed ones.
+func orderColumns(w *SigNozWidget, index map[string]int) {
+	if len(index) == 0 {
+		return
+	}
+	pos := func(name string) int {
+		if i, ok := index[name]; ok {
+			return i
+		}
+		return len(index)
+	}
+	qd := w.Query.Builder.QueryData
+	n := len(qd)
+	sort.SliceStable(qd, func(i, j int) bool {
+		return pos(valueColumnName(qd[i], n)) < pos(valueColumnName(qd[j], n))
+	})
+	for i := range qd {
+		gb := qd[i].GroupBy
+		sort.SliceStable(gb, func(a, b int) bool { return pos(gb[a].Key) < pos(gb[b].Key) })
+	}
+}
+
+func decodeOptions(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func joinErrs(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,145 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// Text panel modes for Rules.TextPanels.
+const (
+	// TextWidget keeps text panels as widgets with their content as the
+	// widget description. SigNoz has no text widget: the widget has no
+	// queries and shows "No Data".
+	TextWidget = "widget"
+	// TextDescription moves the content of text panels into the dashboard
+	// description, one section per panel, and drops the panels. It is the
+	// default.
+	TextDescription = "description"
+)
+
+// isTextPanel reports whether p is a Grafana text panel.
+func isTextPanel(p parser.GrafanaPanel) bool {
+	return strings.EqualFold(p.Type, "text")
+}
+
+// textContent returns the sanitized content of a text panel as markdown.
+// HTML content is reduced to text with links and line breaks; code content
+// is fenced.
+func textContent(p parser.GrafanaPanel) string {
+	var opts struct {
+		Content string `json:"content"`
+		Mode    string `json:"mode"` // markdown, html or code
+		Code    struct {
+			Language string `json:"language"`
+		} `json:"code"`
+	}
+	if len(p.Options) > 0 {
+		_ = json.Unmarshal(p.Options, &opts)
+	}
+	content, mode := nonEmpty(opts.Content, p.Content), nonEmpty(opts.Mode, p.Mode)
+	switch strings.ToLower(mode) {
+	case "html":
+		content = sanitizeHTML(content, true)
+	case "code":
+		content = "```" + opts.Code.Language + "\n" + strings.TrimRight(content, "\n") + "\n```"
+	default:
+		// Markdown may embed HTML.
+		content = sanitizeHTML(content, false)
+	}
+	return strings.TrimSpace(content)
+}
+
+var (
+	// unsafeBlockRegexp matches elements whose content must not be kept.
+	unsafeBlockRegexp = regexp.MustCompile(`(?is)<(script|style|iframe|object|embed|template)\b.*?</(script|style|iframe|object|embed|template)\s*>`)
+	commentRegexp     = regexp.MustCompile(`(?s)<!--.*?-->`)
+	anchorRegexp      = regexp.MustCompile(`(?is)<a\b[^>]*?\bhref\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a\s*>`)
+	lineBreakRegexp   = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr|ul|ol|table|pre|blockquote)\s*>`)
+	listItemRegexp    = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	tagRegexp         = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	blankLinesRegexp  = regexp.MustCompile(`\n{3,}`)
+	// markdownLinkRegexp matches markdown links and images, [text](url) or
+	// [text](url "title"). URLs may hold balanced parentheses.
+	markdownLinkRegexp = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*((?:[^()\s]|\([^()]*\))*)[^()]*\)`)
+)
+
+// unsafeURL reports whether a link URL runs script when opened.
+func unsafeURL(u string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	return strings.HasPrefix(u, "javascript:") || strings.HasPrefix(u, "vbscript:") || strings.HasPrefix(u, "data:")
+}
+
+// sanitizeHTML removes scripts, styles, embedded frames and all other tags
+// from s. Links become markdown links; links with script URLs, HTML or
+// markdown, are reduced to their text. With block set, s is an HTML
+// document: entities are decoded (before tags are removed, so escaped tags
+// are removed too), block elements end lines, list items become "- "
+// lines and indentation is removed.
+func sanitizeHTML(s string, block bool) string {
+	if block {
+		s = html.UnescapeString(s)
+	}
+	s = unsafeBlockRegexp.ReplaceAllString(s, "")
+	s = commentRegexp.ReplaceAllString(s, "")
+	s = anchorRegexp.ReplaceAllStringFunc(s, func(a string) string {
+		m := anchorRegexp.FindStringSubmatch(a)
+		href, text := strings.TrimSpace(m[1]), strings.TrimSpace(tagRegexp.ReplaceAllString(m[2], ""))
+		if unsafeURL(href) || href == "" {
+			return text
+		}
+		return fmt.Sprintf("[%s](%s)", nonEmpty(text, href), href)
+	})
+	if block {
+		s = lineBreakRegexp.ReplaceAllString(s, "\n")
+		s = listItemRegexp.ReplaceAllString(s, "- ")
+	}
+	s = tagRegexp.ReplaceAllString(s, "")
+	s = markdownLinkRegexp.ReplaceAllStringFunc(s, func(l string) string {
+		if m := markdownLinkRegexp.FindStringSubmatch(l); unsafeURL(m[3]) {
+			return m[2]
+		}
+		return l
+	})
+	if !block {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	return blankLinesRegexp.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
+
+// applyTextContent replaces a text panel widget's description with the
+// panel's content. Text panels have no queries, so SigNoz shows the widget
+// as "No Data" with the content in its description tooltip; that is
+// reported.
+func applyTextContent(w *SigNozWidget, p parser.GrafanaPanel) {
+	if !isTextPanel(p) {
+		return
+	}
+	w.Title = nonEmpty(p.Title, "Text")
+	w.Description = textContent(p)
+	w.Warnings = append(w.Warnings, "text panel: SigNoz has no text widget; the widget shows \"No Data\" and the content is only in its description (use --text-panels description)")
+}
+
+// textSections returns the dashboard description sections of text panels
+// converted in TextDescription mode: the panel title as heading, followed
+// by its content. Panels without content are skipped.
+func textSections(panels []parser.GrafanaPanel) []string {
+	var out []string
+	for _, p := range panels {
+		if !isTextPanel(p) {
+			continue
+		}
+		if c := textContent(p); c != "" {
+			out = append(out, fmt.Sprintf("### %s\n\n%s", nonEmpty(p.Title, "Notes"), c))
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package mapper
+
+import (
+	"fmt"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// timePreferences are SigNoz's fixed widget time ranges, shortest first.
+var timePreferences = []struct {
+	name    string
+	seconds int
+}{
+	{"LAST_5_MIN", 300},
+	{"LAST_15_MIN", 900},
+	{"LAST_30_MIN", 1800},
+	{"LAST_1_HR", 3600},
+	{"LAST_6_HR", 21600},
+	{"LAST_1_DAY", 86400},
+	{"LAST_3_DAYS", 259200},
+	{"LAST_1_WEEK", 604800},
+	{"LAST_1_MONTH", 2592000},
+}
+
+// timePreference returns the shortest SigNoz time preference covering a
+// Grafana relative time ("1h", "now-7d"), and whether it matches exactly.
+// It returns "" for ranges that cannot be parsed.
+func timePreference(rel string) (string, bool) {
+	secs := durationSeconds(strings.TrimPrefix(strings.TrimSpace(rel), "now-"))
+	if secs == 0 {
+		return "", false
+	}
+	for _, tp := range timePreferences {
+		if secs <= tp.seconds {
+			return tp.name, secs == tp.seconds
+		}
+	}
+	return timePreferences[len(timePreferences)-1].name, false
+}
+
+// applyTimeOverrides maps the panel's relative time override onto the
+// widget's time preference, and its time shift onto an offset function of
+// every builder query. hideTimeOverride only affects the panel header.
+func applyTimeOverrides(w *SigNozWidget, p parser.GrafanaPanel) {
+	if p.TimeFrom != "" {
+		tp, exact := timePreference(p.TimeFrom)
+		switch {
+		case tp == "":
+			w.Warnings = append(w.Warnings, fmt.Sprintf("relative time %q is not supported; widget uses the dashboard time", p.TimeFrom))
+		case !exact:
+			w.Warnings = append(w.Warnings, fmt.Sprintf("relative time %q is approximated by %s", p.TimeFrom, tp))
+			fallthrough
+		default:
+			w.TimePreference = tp
+		}
+	}
+	if shift := strings.TrimSpace(p.TimeShift); shift != "" {
+		if durationSeconds(shift) == 0 {
+			w.Warnings = append(w.Warnings, fmt.Sprintf("time shift %q is not supported", p.TimeShift))
+			return
+		}
+		for i := range w.Query.Builder.QueryData {
+			q := &w.Query.Builder.QueryData[i]
+			q.Functions = append(q.Functions, Function{
+				Name: "offset",
+				Args: map[string]interface{}{"duration": shift},
+			})
+		}
+	}
+}
+
+// reportTimeSettings notes the dashboard time settings SigNoz cannot
+// store. The default range and refresh are kept in _grafana.
+func reportTimeSettings(g *parser.GrafanaDashboard, r *Report) {
+	switch tz := strings.ToLower(g.Timezone); tz {
+	case "", "browser":
+	default:
+		r.add("", "", fmt.Sprintf("dashboard timezone %q is not converted; SigNoz uses the user's timezone setting", g.Timezone))
+	}
+	if g.Timepicker.Hidden {
+		r.add("", "", "the time picker is hidden in Grafana; SigNoz always shows it")
+	}
+	if g.Timepicker.NowDelay != "" {
+		r.add("", "", fmt.Sprintf("time picker now delay %q is not converted", g.Timepicker.NowDelay))
+	}
+}
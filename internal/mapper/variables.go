@@ -0,0 +1,508 @@
+package mapper
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"grafana2signoz/internal/parser"
+)
+
+// seriesTable is the SigNoz table QUERY variables read label values from.
+const seriesTable = "signoz_metrics.distributed_time_series_v4_1day"
+
+// variableTypes maps Grafana variable types onto SigNoz ones. Constant and
+// interval variables are inlined into queries (see inlineVariables);
+// datasource variables are resolved by type; other types are dropped.
+var variableTypes = map[string]string{
+	"":        "QUERY",
+	"query":   "QUERY",
+	"custom":  "CUSTOM",
+	"textbox": "TEXTBOX",
+}
+
+// buildVariables converts Grafana variables into SigNoz-like variable objects (best-effort).
+// Prometheus query variables become ClickHouse SQL; queries that cannot be
+// translated are kept as is and reported. Variables are ordered after the
+// variables they reference (see variableOrder).
+func buildVariables(g *parser.GrafanaDashboard, ds *datasources, ids *ids, r *Report) map[string]interface{} {
+	byName := map[string]parser.GrafanaVariable{}
+	for _, v := range g.Templating.List {
+		byName[v.Name] = v
+	}
+	inlined := inlinedValues(g)
+	position := map[int]int{}
+	for pos, i := range variableOrder(g.Templating.List, inlined, r) {
+		position[i] = pos
+	}
+	out := map[string]interface{}{}
+	for i, v := range g.Templating.List {
+		typ, ok := variableTypes[strings.ToLower(v.Type)]
+		if !ok {
+			switch strings.ToLower(v.Type) {
+			case "constant", "interval", "datasource":
+			case "adhoc":
+				r.add("", "", fmt.Sprintf("variable $%s: ad hoc filters are not supported; variable dropped", v.Name))
+			default:
+				r.add("", "", fmt.Sprintf("variable $%s: type %q is not supported; variable dropped", v.Name, v.Type))
+			}
+			continue
+		}
+		name := v.Name
+		if name == "" {
+			name = fmt.Sprintf("var_%d", i)
+		}
+		id := ids.id("variable", name)
+		var queryValue interface{} = ""
+		customValue, textboxValue := "", ""
+		switch typ {
+		case "QUERY":
+			q := inlineText(variableQuery(v), inlined)
+			queryValue = q
+			if dt := ds.typeOf(v.Datasource); dt != "" && dt != dsPrometheus {
+				r.add("", "", fmt.Sprintf("variable $%s: %s query %q is not converted", name, dt, q))
+			} else if sql, err := variableSQL(q, v.Regex, byName); err != nil {
+				r.add("", "", fmt.Sprintf("variable $%s: %v; query kept as is", name, err))
+			} else {
+				queryValue = sql
+			}
+		case "CUSTOM":
+			customValue = strings.Join(customValues(v), ",")
+		case "TEXTBOX":
+			textboxValue = nonEmpty(firstValue(v.Current), fmt.Sprint(nonNil(v.Query)))
+		}
+		if v.Hide == 2 {
+			r.add("", "", fmt.Sprintf("variable $%s is hidden in Grafana; SigNoz shows it", name))
+		}
+		selected, all := selectedValues(v)
+		variable := map[string]interface{}{
+			"id":               id,
+			"name":             name,
+			"type":             typ,
+			"modificationUUID": ids.id("variable", name, "modification"),
+			"queryValue":       queryValue,
+			"multiSelect":      v.Multi,
+			"showALLOption":    v.IncludeAll,
+			"order":            position[i],
+			"description":      v.Label,
+			"sort":             variableSort(v.Sort),
+			"customValue":      customValue,
+			"textboxValue":     textboxValue,
+			"allSelected":      all,
+		}
+		if len(selected) > 0 {
+			if v.Multi {
+				variable["selectedValue"] = selected
+			} else {
+				variable["selectedValue"] = selected[0]
+			}
+		}
+		out[id] = variable
+	}
+	return out
+}
+
+// variableSort maps Grafana sort codes onto SigNoz's ASC/DESC. SigNoz sorts
+// values as strings, so numerical and natural orders keep only direction.
+func variableSort(code int) string {
+	switch {
+	case code <= 0 || code > 8:
+		return "DISABLED"
+	case code%2 == 1:
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// selectedValues returns a variable's selection from current.value, or the
+// options marked selected, and whether All is selected. With All selected
+// the selection lists the known option values.
+func selectedValues(v parser.GrafanaVariable) ([]string, bool) {
+	vals := optionValues(v.Current)
+	if len(vals) == 0 {
+		for _, o := range v.Options {
+			if o.Selected {
+				vals = append(vals, stringValues(o.Value)...)
+			}
+		}
+	}
+	if !contains(vals, "$__all") {
+		return vals, false
+	}
+	var all []string
+	for _, o := range v.Options {
+		for _, val := range stringValues(o.Value) {
+			if val != "$__all" {
+				all = append(all, val)
+			}
+		}
+	}
+	return all, true
+}
+
+// customValues returns the values of a custom variable: its resolved
+// options, or the comma-separated query ("text : value" keeps the value).
+func customValues(v parser.GrafanaVariable) []string {
+	var out []string
+	for _, o := range v.Options {
+		for _, val := range stringValues(o.Value) {
+			if val != "$__all" && !contains(out, val) {
+				out = append(out, val)
+			}
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	q, _ := v.Query.(string)
+	for _, item := range splitCSV(q) {
+		if _, val, ok := strings.Cut(item, " : "); ok {
+			item = val
+		}
+		if item = strings.TrimSpace(item); item != "" && !contains(out, item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// inlinedValues returns the values of constant and interval variables,
+// which SigNoz has no counterpart for and are substituted into queries.
+// Interval variables use the current value, or the first non-auto option.
+func inlinedValues(g *parser.GrafanaDashboard) map[string]string {
+	out := map[string]string{}
+	for _, v := range g.Templating.List {
+		q, _ := v.Query.(string)
+		switch strings.ToLower(v.Type) {
+		case "constant":
+			out[v.Name] = nonEmpty(firstValue(v.Current), q)
+		case "interval":
+			val := firstValue(v.Current)
+			if val == "" || strings.HasPrefix(val, "$__auto") || val == "auto" {
+				val = ""
+				for _, opt := range splitCSV(q) {
+					if opt != "" && opt != "auto" {
+						val = opt
+						break
+					}
+				}
+			}
+			if val != "" {
+				out[v.Name] = val
+			}
+		}
+	}
+	return out
+}
+
+// inlineVariables substitutes constant and interval variables into panel
+// titles, target expressions and legends. Targets using an interval
+// variable without their own interval take it as their step.
+func inlineVariables(g *parser.GrafanaDashboard, panels []parser.GrafanaPanel) []parser.GrafanaPanel {
+	vals := inlinedValues(g)
+	intervals := map[string]bool{}
+	for _, v := range g.Templating.List {
+		if strings.EqualFold(v.Type, "interval") {
+			intervals[v.Name] = true
+		}
+	}
+	for i := range panels {
+		for _, name := range sortedKeys(vals) {
+			if intervals[name] {
+				re := variableRefRegexp(name)
+				for j, t := range panels[i].Targets {
+					if t.Interval == "" && re.MatchString(t.Expr) {
+						panels[i].Targets[j].Interval = vals[name]
+					}
+				}
+			}
+			panels[i] = substitutePanel(panels[i], name, vals[name])
+		}
+	}
+	return panels
+}
+
+func inlineText(s string, vals map[string]string) string {
+	for _, name := range sortedKeys(vals) {
+		s = variableRefRegexp(name).ReplaceAllLiteralString(s, vals[name])
+	}
+	return s
+}
+
+func firstValue(cur interface{}) string {
+	if vs := optionValues(cur); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+func nonNil(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+var durationRegexp = regexp.MustCompile(`^>?\s*(\d+)(s|m|h|d|w)$`)
+
+// durationSeconds parses a Grafana interval such as 30s, 5m, 1h or >1m
+// into seconds; it returns 0 for anything else.
+func durationSeconds(s string) int {
+	m := durationRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	unit := map[string]int{"s": 1, "m": 60, "h": 3600, "d": 86400, "w": 604800}[m[2]]
+	return n * unit
+}
+
+// stepInterval is the SigNoz stepInterval for a target's min interval,
+// 60 seconds by default.
+func stepInterval(interval string) int {
+	if s := durationSeconds(interval); s > 0 {
+		return s
+	}
+	return 60
+}
+
+// variableQuery returns a query variable's query string. Newer Prometheus
+// variables store {"query": ..., "refId": ...} objects.
+func variableQuery(v parser.GrafanaVariable) string {
+	switch q := v.Query.(type) {
+	case string:
+		return q
+	case map[string]interface{}:
+		if s, ok := q["query"].(string); ok {
+			return s
+		}
+	}
+	return v.Definition
+}
+
+var (
+	variableFuncRegexp = regexp.MustCompile(`^(label_values|label_names|metrics|query_result)\s*\((.*)\)$`)
+	selectorRegexp     = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)?\s*(\{.*\})?$`)
+	variableOnlyRegexp = regexp.MustCompile(`^(?:\$\{([\w.]+)(?::[^}]*)?\}|\$([\w.]+)|\[\[([\w.]+)(?::[^\]]*)?\]\])$`)
+	// labelCaptureRegexp matches the usual query_result regex that extracts
+	// one label from the result series, e.g. /.*pod="([^"]+)".*/.
+	labelCaptureRegexp = regexp.MustCompile(`^\.\*\{?(\w+)="\([^)]*\)".*$`)
+)
+
+// variableSelect is a SQL query listing variable values: column AS alias,
+// grouped by alias.
+type variableSelect struct {
+	column, alias string
+	where, having []string
+}
+
+func (s variableSelect) sql() string {
+	sel := s.column
+	if s.column != s.alias {
+		sel += " AS " + s.alias
+	}
+	out := fmt.Sprintf("SELECT %s FROM %s%s GROUP BY %s", sel, seriesTable, whereClause(s.where), s.alias)
+	if len(s.having) > 0 {
+		out += " HAVING " + strings.Join(s.having, " AND ")
+	}
+	return out
+}
+
+// variableSQL translates a Prometheus variable query into ClickHouse SQL
+// over SigNoz's time series table, modelled on SigNoz's own dashboards:
+//
+//	SELECT JSONExtractString(labels, 'pod') AS `pod` FROM ... WHERE metric_name = 'up' AND JSONExtractString(labels, 'job') = $job GROUP BY `pod`
+//
+// label_values, label_names, metrics and query_result (by a single label,
+// or with a regex extracting one label) are supported. Label matchers on
+// other variables become chained filters. The variable regex filters the
+// values, or extracts its first capture group from them.
+func variableSQL(q, regex string, vars map[string]parser.GrafanaVariable) (string, error) {
+	m := variableFuncRegexp.FindStringSubmatch(strings.TrimSpace(q))
+	if m == nil {
+		return "", fmt.Errorf("query %q is not supported", q)
+	}
+	re, err := variableRegex(regex)
+	if err != nil {
+		return "", err
+	}
+	args := strings.TrimSpace(m[2])
+	var s variableSelect
+	switch m[1] {
+	case "label_values":
+		sel, label := "", args
+		if i := strings.LastIndex(args, ","); i >= 0 && i > strings.LastIndex(args, "}") {
+			sel, label = args[:i], args[i+1:]
+		}
+		if s, err = labelValuesSelect(sel, strings.TrimSpace(label), vars); err != nil {
+			return "", err
+		}
+	case "label_names":
+		where, err := selectorConditions(args, vars)
+		if err != nil {
+			return "", err
+		}
+		s = variableSelect{column: "arrayJoin(JSONExtractKeys(labels))", alias: "label", where: where, having: []string{"label != '__name__'"}}
+	case "metrics":
+		s = variableSelect{column: "metric_name", alias: "metric_name"}
+		if pat := strings.Trim(args, "\"' "); pat != "" {
+			s.where = []string{fmt.Sprintf("match(metric_name, %s)", sqlString(pat))}
+		}
+	case "query_result":
+		p := parsePromQL(args)
+		if !selectorRegexp.MatchString(p.Metric) {
+			return "", fmt.Errorf("query_result(%s) is not supported", args)
+		}
+		label := ""
+		if c := labelCaptureRegexp.FindStringSubmatch(re); c != nil {
+			// The regex picks the label out of the result series.
+			label, re = c[1], ""
+		} else if len(p.By) == 1 {
+			label = p.By[0]
+		} else {
+			return "", fmt.Errorf("query_result(%s) does not aggregate by a single label", args)
+		}
+		// Label values are kept as written, selectorConditions unescapes them.
+		sel := p.Metric
+		if len(p.Labels) > 0 {
+			parts := make([]string, len(p.Labels))
+			for i, l := range p.Labels {
+				parts[i] = fmt.Sprintf(`%s%s"%s"`, l.Key, l.Op, l.Value)
+			}
+			sel += "{" + strings.Join(parts, ",") + "}"
+		}
+		if s, err = labelValuesSelect(sel, label, vars); err != nil {
+			return "", err
+		}
+	}
+	if re != "" {
+		if compiled := regexp.MustCompile(re); compiled.NumSubexp() > 0 {
+			s.column = fmt.Sprintf("extract(%s, %s)", s.column, sqlString(re))
+			s.having = append(s.having, s.alias+" != ''")
+		} else {
+			s.having = append(s.having, fmt.Sprintf("match(%s, %s)", s.alias, sqlString(re)))
+		}
+	}
+	return s.sql(), nil
+}
+
+// variableRegex converts a Grafana variable regex (/pattern/flags or a bare
+// pattern) into an RE2 pattern. Named text/value groups are not supported.
+func variableRegex(regex string) (string, error) {
+	regex = strings.TrimSpace(regex)
+	if regex == "" {
+		return "", nil
+	}
+	pat := regex
+	if i := strings.LastIndex(regex, "/"); strings.HasPrefix(regex, "/") && i > 0 {
+		pat = regex[1:i]
+		if strings.Contains(regex[i+1:], "i") {
+			pat = "(?i)" + pat
+		}
+	}
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		return "", fmt.Errorf("regex %s: %v", regex, err)
+	}
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return "", fmt.Errorf("regex %s: named groups are not supported", regex)
+		}
+	}
+	return pat, nil
+}
+
+func labelValuesSelect(sel, label string, vars map[string]parser.GrafanaVariable) (variableSelect, error) {
+	if label == "" {
+		return variableSelect{}, fmt.Errorf("label_values without a label")
+	}
+	where, err := selectorConditions(sel, vars)
+	if err != nil {
+		return variableSelect{}, err
+	}
+	col := labelColumn(label)
+	if len(where) == 0 {
+		where = []string{col + " != ''"}
+	}
+	return variableSelect{column: col, alias: "`" + label + "`", where: where}, nil
+}
+
+// selectorConditions translates a series selector metric{label="value",...}
+// into SQL conditions.
+func selectorConditions(sel string, vars map[string]parser.GrafanaVariable) ([]string, error) {
+	sel = strings.TrimSpace(sel)
+	if sel == "" {
+		return nil, nil
+	}
+	m := selectorRegexp.FindStringSubmatch(sel)
+	if m == nil {
+		return nil, fmt.Errorf("selector %q is not supported", sel)
+	}
+	var where []string
+	if m[1] != "" {
+		where = append(where, "metric_name = "+sqlString(m[1]))
+	}
+	for _, l := range parseLabelSet(m[2]) {
+		col := labelColumn(l.Key)
+		if l.Key == "__name__" {
+			col = "metric_name"
+		}
+		c, err := matcherCondition(col, l, vars)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, c)
+	}
+	return where, nil
+}
+
+// matcherCondition translates one label matcher. A value that is exactly a
+// variable reference filters on the SigNoz variable ($name), with IN for
+// multi-value variables and regex matchers (Grafana's multi-value idiom).
+func matcherCondition(col string, l labelMatcher, vars map[string]parser.GrafanaVariable) (string, error) {
+	if m := variableOnlyRegexp.FindStringSubmatch(l.Value); m != nil {
+		name := m[1] + m[2] + m[3]
+		v := vars[name]
+		in := v.Multi || v.IncludeAll || strings.HasSuffix(l.Op, "~")
+		switch {
+		case in && strings.HasPrefix(l.Op, "!"):
+			return fmt.Sprintf("%s NOT IN $%s", col, name), nil
+		case in:
+			return fmt.Sprintf("%s IN $%s", col, name), nil
+		case l.Op == "!=":
+			return fmt.Sprintf("%s != $%s", col, name), nil
+		}
+		return fmt.Sprintf("%s = $%s", col, name), nil
+	}
+	if looksLikeTemplate(l.Value) {
+		return "", fmt.Errorf(`matcher %s%s"%s" mixes a variable with text`, l.Key, l.Op, l.Value)
+	}
+	val := l.Value
+	if u, err := strconv.Unquote(`"` + val + `"`); err == nil {
+		val = u
+	}
+	switch l.Op {
+	case "=~":
+		return fmt.Sprintf("match(%s, %s)", col, sqlString("^(?:"+val+")$")), nil
+	case "!~":
+		return fmt.Sprintf("NOT match(%s, %s)", col, sqlString("^(?:"+val+")$")), nil
+	}
+	return fmt.Sprintf("%s %s %s", col, l.Op, sqlString(val)), nil
+}
+
+func labelColumn(label string) string {
+	return fmt.Sprintf("JSONExtractString(labels, %s)", sqlString(label))
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// sqlString quotes s as a ClickHouse string literal.
+func sqlString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
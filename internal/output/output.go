@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 
 	"grafana2signoz/internal/mapper"
 )
@@ -41,6 +42,7 @@ func ValidateSigNozDashboard(d mapper.SigNozDashboard) []error {
 	}
 	// Track widget ids
 	ids := map[string]bool{}
+	rows := map[string]bool{}
 	for i, w := range d.Widgets {
 		if w.ID == "" {
 			errs = append(errs, fmt.Errorf("widgets[%d]: id is required", i))
@@ -51,13 +53,36 @@ func ValidateSigNozDashboard(d mapper.SigNozDashboard) []error {
 		if !isSupportedPanel(w.PanelType) {
 			errs = append(errs, fmt.Errorf("widgets[%d]: unsupported panelTypes '%s'", i, w.PanelType))
 		}
-		if w.TimePreference == "" {
+		if w.TimePreference == "" && w.PanelType != "row" {
 			errs = append(errs, fmt.Errorf("widgets[%d]: timePreferance is required", i))
 		}
+		if !isSupportedQueryType(w.Query.QueryType) {
+			errs = append(errs, fmt.Errorf("widgets[%d]: unsupported queryType '%s'", i, w.Query.QueryType))
+		}
 		if ids[w.ID] {
 			errs = append(errs, fmt.Errorf("duplicate widget id '%s'", w.ID))
 		}
 		ids[w.ID] = true
+		if w.PanelType == "row" {
+			rows[w.ID] = true
+		}
+	}
+	// Validate row membership
+	rowIDs := make([]string, 0, len(d.PanelMap))
+	for rowID := range d.PanelMap {
+		rowIDs = append(rowIDs, rowID)
+	}
+	sort.Strings(rowIDs)
+	for _, rowID := range rowIDs {
+		entry := d.PanelMap[rowID]
+		if !rows[rowID] {
+			errs = append(errs, fmt.Errorf("panelMap: '%s' is not a row widget", rowID))
+		}
+		for _, l := range entry.Widgets {
+			if !ids[l.I] {
+				errs = append(errs, fmt.Errorf("panelMap[%s]: references unknown widget id '%s'", rowID, l.I))
+			}
+		}
 	}
 	// Validate layout references
 	for i, l := range d.Layout {
@@ -82,3 +107,33 @@ func isSupportedPanel(p string) bool {
 		return false
 	}
 }
+
+func isSupportedQueryType(q string) bool {
+	switch q {
+	case "", "builder", "promql", "clickhouse_sql":
+		return true
+	default:
+		return false
+	}
+}
+
+// WriteReport writes a conversion report as pretty JSON.
+func WriteReport(w io.Writer, r *mapper.Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// PrintReport writes one human-readable line per report item.
+func PrintReport(w io.Writer, r *mapper.Report) {
+	if r == nil {
+		return
+	}
+	for _, it := range r.Items {
+		if it.Widget == "" {
+			fmt.Fprintf(w, "report: %s: %s\n", r.Dashboard, it.Message)
+			continue
+		}
+		fmt.Fprintf(w, "report: %s: widget %s (%q): %s\n", r.Dashboard, it.Widget, it.Title, it.Message)
+	}
+}
@@ -18,7 +18,7 @@ func TestValidateAndWrite(t *testing.T) {
 			Title:          "A",
 			PanelType:      "graph",
 			TimePreference: "GLOBAL_TIME",
-			Query:          map[string]interface{}{"queryType": "builder", "builder": map[string]interface{}{}},
+			Query:          mapper.Query{QueryType: "builder"},
 		}},
 		Layout: []mapper.SigNozLayout{{I: "w_1", W: 6, H: 6, X: 0, Y: 0}},
 	}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GrafanaMeta is the "meta" object returned next to the dashboard by the
+// Grafana HTTP API (GET /api/dashboards/uid/:uid).
+type GrafanaMeta struct {
+	Slug        string `json:"slug"`
+	URL         string `json:"url"`
+	FolderID    int    `json:"folderId"`
+	FolderUID   string `json:"folderUid"`
+	FolderTitle string `json:"folderTitle"`
+	FolderURL   string `json:"folderUrl"`
+	Created     string `json:"created"`
+	Updated     string `json:"updated"`
+	CreatedBy   string `json:"createdBy"`
+	UpdatedBy   string `json:"updatedBy"`
+	Version     int    `json:"version"`
+}
+
+// GrafanaInput is an "__inputs" entry of a dashboard exported for sharing
+// (or downloaded from grafana.com). Datasource inputs are referenced as
+// ${<name>} in the dashboard; constant inputs carry a Value.
+type GrafanaInput struct {
+	Name        string `json:"name"`
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Type        string `json:"type"` // datasource or constant
+	PluginID    string `json:"pluginId"`
+	PluginName  string `json:"pluginName"`
+	Value       string `json:"value"`
+}
+
+// GrafanaRequire is a "__requires" entry naming a plugin the dashboard uses.
+type GrafanaRequire struct {
+	Type    string `json:"type"` // grafana, panel or datasource
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// unwrapEnvelope returns the dashboard JSON from an API response of the form
+// {"dashboard": {...}, "meta": {...}}, or b itself when it is not wrapped.
+func unwrapEnvelope(b []byte) ([]byte, *GrafanaMeta, error) {
+	var env struct {
+		Dashboard json.RawMessage `json:"dashboard"`
+		Meta      *GrafanaMeta    `json:"meta"`
+	}
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, nil, fmt.Errorf("decode grafana json: %w", err)
+	}
+	if len(env.Dashboard) == 0 || string(env.Dashboard) == "null" {
+		return b, nil, nil
+	}
+	return env.Dashboard, env.Meta, nil
+}
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GrafanaLegacyRow is a row of a pre-5.0 dashboard. Panels are laid out left
+// to right by span (12 per line) at the row's pixel height.
+type GrafanaLegacyRow struct {
+	Title     string         `json:"title"`
+	ShowTitle bool           `json:"showTitle"`
+	Collapse  bool           `json:"collapse"`
+	Height    interface{}    `json:"height"`
+	Repeat    string         `json:"repeat"`
+	Panels    []GrafanaPanel `json:"panels"`
+}
+
+// Grafana's grid cell size, used to convert pixel heights to grid units.
+const (
+	gridCellHeight  = 30
+	gridCellVMargin = 8
+	minPanelHeight  = 3 * gridCellHeight
+	defaultRowPx    = 250
+	defaultSpan     = 4
+)
+
+// migrateRows converts legacy rows into gridPos panels the way Grafana's
+// schema migration does: row panels are only created when some row shows
+// its title, collapses or repeats; spans are doubled onto the 24-column
+// grid; panels of collapsed rows are nested in the row panel.
+func migrateRows(rows []GrafanaLegacyRow) []GrafanaPanel {
+	showRows := false
+	nextID := 1
+	for _, r := range rows {
+		if r.ShowTitle || r.Collapse || r.Repeat != "" {
+			showRows = true
+		}
+		for _, p := range r.Panels {
+			if p.ID >= nextID {
+				nextID = p.ID + 1
+			}
+		}
+	}
+
+	var out []GrafanaPanel
+	y := 0
+	for _, r := range rows {
+		var row *GrafanaPanel
+		if showRows {
+			out = append(out, GrafanaPanel{
+				ID:        nextID,
+				Type:      "row",
+				Title:     r.Title,
+				Collapsed: r.Collapse,
+				Repeat:    r.Repeat,
+				GridPos:   &GrafanaGridPos{X: 0, Y: y, W: 24, H: 1},
+			})
+			row = &out[len(out)-1]
+			nextID++
+			y++
+		}
+
+		rowH := gridHeight(r.Height)
+		x, lineH := 0, 0
+		for _, p := range r.Panels {
+			span := p.Span
+			if span <= 0 {
+				span = defaultSpan
+			}
+			w := int(math.Round(span * 2))
+			if w > 24 {
+				w = 24
+			}
+			h := rowH
+			if p.Height != nil {
+				h = gridHeight(p.Height)
+			}
+			if x+w > 24 {
+				y += lineH
+				x, lineH = 0, 0
+			}
+			p.GridPos = &GrafanaGridPos{X: x, Y: y, W: w, H: h}
+			x += w
+			if h > lineH {
+				lineH = h
+			}
+			if row != nil && r.Collapse {
+				row.Panels = append(row.Panels, p)
+				continue
+			}
+			out = append(out, p)
+		}
+		// Panels of a collapsed row do not take space on the dashboard.
+		if row == nil || !r.Collapse {
+			y += lineH
+		}
+	}
+	return out
+}
+
+// gridHeight converts a legacy pixel height ("250px" or 250) to grid units.
+func gridHeight(v interface{}) int {
+	px := float64(defaultRowPx)
+	switch h := v.(type) {
+	case float64:
+		px = h
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(h), "px"), 64); err == nil {
+			px = f
+		}
+	}
+	if px < minPanelHeight {
+		px = minPanelHeight
+	}
+	return int(math.Ceil(px / (gridCellHeight + gridCellVMargin)))
+}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// GrafanaLibraryPanelRef references a library panel from a dashboard.
+type GrafanaLibraryPanelRef struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+// GrafanaLibraryElement is a library panel as exported by Grafana: the
+// panel model plus its identity.
+type GrafanaLibraryElement struct {
+	UID   string          `json:"uid"`
+	Name  string          `json:"name"`
+	Kind  int             `json:"kind"`
+	Model json.RawMessage `json:"model"`
+}
+
+// LibraryPanels indexes library elements by uid.
+type LibraryPanels map[string]GrafanaLibraryElement
+
+// LoadLibraryPanels reads library panels from a JSON export file or from
+// every .json file in a directory. Accepted layouts are a single element,
+// a list of elements, {"elements": [...]}, the /api/library-elements
+// response {"result": {"elements": [...]}} and a dashboard "__elements" map.
+func LoadLibraryPanels(path string) (LibraryPanels, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = files[:0]
+		for _, e := range entries {
+			if !e.IsDir() && filepath.Ext(e.Name()) == ".json" {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	lib := LibraryPanels{}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		els, err := parseLibraryElements(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		for _, el := range els {
+			lib[el.UID] = el
+		}
+	}
+	return lib, nil
+}
+
+func parseLibraryElements(b []byte) ([]GrafanaLibraryElement, error) {
+	var list []GrafanaLibraryElement
+	if err := json.Unmarshal(b, &list); err == nil {
+		return list, nil
+	}
+	var obj struct {
+		GrafanaLibraryElement
+		Elements json.RawMessage `json:"elements"`
+		Result   struct {
+			Elements []GrafanaLibraryElement `json:"elements"`
+		} `json:"result"`
+		DashElements LibraryPanels `json:"__elements"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, fmt.Errorf("decode library panels: %w", err)
+	}
+	switch {
+	case len(obj.Model) > 0:
+		return []GrafanaLibraryElement{obj.GrafanaLibraryElement}, nil
+	case len(obj.Result.Elements) > 0:
+		return obj.Result.Elements, nil
+	case len(obj.Elements) > 0:
+		if err := json.Unmarshal(obj.Elements, &list); err != nil {
+			return nil, fmt.Errorf("decode library panels: %w", err)
+		}
+		return list, nil
+	}
+	for uid, el := range obj.DashElements {
+		if el.UID == "" {
+			el.UID = uid
+		}
+		list = append(list, el)
+	}
+	return list, nil
+}
+
+// ResolveLibraryPanels inlines library panel models into the dashboard.
+// The dashboard keeps the panel's id, grid position and row; the model
+// supplies everything else. Panels are matched by uid, then by name; of
+// several elements sharing a name, the one with the lowest uid is used.
+// References that cannot be resolved are left in place and returned.
+func ResolveLibraryPanels(d *GrafanaDashboard, lib LibraryPanels) []GrafanaLibraryPanelRef {
+	uids := make([]string, 0, len(lib))
+	for uid := range lib {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	byName := map[string]GrafanaLibraryElement{}
+	for _, uid := range uids {
+		el := lib[uid]
+		if _, ok := byName[el.Name]; !ok {
+			byName[el.Name] = el
+		}
+	}
+	var missing []GrafanaLibraryPanelRef
+	for i, p := range d.Panels {
+		if p.LibraryPanel == nil {
+			continue
+		}
+		el, ok := lib[p.LibraryPanel.UID]
+		if !ok {
+			el, ok = byName[p.LibraryPanel.Name]
+		}
+		var model GrafanaPanel
+		if !ok || json.Unmarshal(el.Model, &model) != nil {
+			missing = append(missing, *p.LibraryPanel)
+			continue
+		}
+		model.ID, model.GridPos, model.RowID = p.ID, p.GridPos, p.RowID
+		model.Panels, model.LibraryPanel = nil, nil
+		if model.Title == "" {
+			model.Title = nonEmptyTitle(p.Title, el.Name)
+		}
+		d.Panels[i] = model
+	}
+	return missing
+}
+
+func nonEmptyTitle(title, def string) string {
+	if title == "" {
+		return def
+	}
+	return title
+}
@@ -5,16 +5,40 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 )
 
 // Minimal Grafana dashboard structures covering common fields used in mapping.
 // Many fields are intentionally simplified; unknown fields are ignored.
 
 type GrafanaDashboard struct {
-	Title      string          `json:"title"`
-	UID        string          `json:"uid"`
-	Templating GrafanaTemplate `json:"templating"`
-	Panels     []GrafanaPanel  `json:"panels"`
+	Title       string          `json:"title"`
+	UID         string          `json:"uid"`
+	Description string          `json:"description"`
+	Tags        []string        `json:"tags"`
+	Version     int             `json:"version"`
+	Templating  GrafanaTemplate `json:"templating"`
+	Panels      []GrafanaPanel  `json:"panels"`
+	Links       []GrafanaLink   `json:"links"`
+	// Default time range, auto-refresh interval ("30s", or false) and
+	// timezone ("browser", "utc" or a location).
+	Time       *GrafanaTimeRange `json:"time"`
+	Refresh    interface{}       `json:"refresh"`
+	Timezone   string            `json:"timezone"`
+	Timepicker GrafanaTimepicker `json:"timepicker"`
+	// Annotations are queries for events shown on time series panels.
+	Annotations GrafanaAnnotations `json:"annotations"`
+	// SchemaVersion and Rows describe pre-5.0 dashboards (schemaVersion < 16)
+	// whose panels live in rows; the parser migrates them into Panels.
+	SchemaVersion int                `json:"schemaVersion"`
+	Rows          []GrafanaLegacyRow `json:"rows"`
+	// Inputs, Requires and Elements are added by "export for sharing".
+	// Elements holds the embedded library panels.
+	Inputs   []GrafanaInput   `json:"__inputs"`
+	Requires []GrafanaRequire `json:"__requires"`
+	Elements LibraryPanels    `json:"__elements"`
+	// Meta is set when the dashboard was wrapped in an API response.
+	Meta *GrafanaMeta `json:"-"`
 }
 
 type GrafanaTemplate struct {
@@ -25,10 +49,29 @@ type GrafanaVariable struct {
 	Name       string      `json:"name"`
 	Type       string      `json:"type"`
 	Query      interface{} `json:"query"`
+	Definition string      `json:"definition"` // query as typed, when Query is an object
+	Datasource interface{} `json:"datasource"`
 	Current    interface{} `json:"current"`
 	Label      string      `json:"label"`
 	IncludeAll bool        `json:"includeAll"`
 	Multi      bool        `json:"multi"`
+	// Regex filters the query results, or extracts its first capture group.
+	Regex string `json:"regex"`
+	// Sort is Grafana's sort code: 0 disabled, 1/2 alphabetical asc/desc,
+	// 3/4 numerical, 5/6 case-insensitive alphabetical, 7/8 natural.
+	Sort int `json:"sort"`
+	// Hide is 0 (shown), 1 (label hidden) or 2 (variable hidden).
+	Hide int `json:"hide"`
+	// Options are the values Grafana last resolved for the variable.
+	Options []GrafanaVariableOption `json:"options"`
+}
+
+// GrafanaVariableOption is one selectable variable value. Text and Value are
+// strings, or string lists for multi-value selections.
+type GrafanaVariableOption struct {
+	Text     interface{} `json:"text"`
+	Value    interface{} `json:"value"`
+	Selected bool        `json:"selected"`
 }
 
 type GrafanaPanel struct {
@@ -40,8 +83,110 @@ type GrafanaPanel struct {
 	GridPos    *GrafanaGridPos `json:"gridPos"`
 	Options    json.RawMessage `json:"options"`
 	FieldCfg   json.RawMessage `json:"fieldConfig"`
-	// Some Grafana dashboards nest rows; for simplicity we flatten if present.
-	Panels []GrafanaPanel `json:"panels"`
+	// Data transformations applied in order (organize, merge, reduce, ...).
+	Transformations []GrafanaTransformation `json:"transformations"`
+	// Legacy graph panel display settings (pre-timeseries).
+	Stack         bool   `json:"stack"`
+	Fill          *int   `json:"fill"`
+	Bars          bool   `json:"bars"`
+	Lines         *bool  `json:"lines"`
+	NullPointMode string `json:"nullPointMode"`
+	// Legacy singlestat reducer (avg, current, max, min, total).
+	ValueName string `json:"valueName"`
+	// Legacy singlestat value/range to text mappings.
+	ValueMaps []GrafanaValueMap `json:"valueMaps"`
+	RangeMaps []GrafanaRangeMap `json:"rangeMaps"`
+	// Collapsed rows nest their panels; the parser flattens them after the row.
+	Panels    []GrafanaPanel `json:"panels"`
+	Collapsed bool           `json:"collapsed"`
+	// Repeat names the variable a panel or row is repeated for.
+	Repeat          string `json:"repeat"`
+	RepeatDirection string `json:"repeatDirection"` // h or v
+	MaxPerRow       int    `json:"maxPerRow"`
+	// Legacy text panel content and mode (markdown, html); newer text
+	// panels keep both in Options.
+	Content string `json:"content"`
+	Mode    string `json:"mode"`
+	// Legacy row layout: width in 12ths of the row and height in pixels.
+	Span   float64     `json:"span"`
+	Height interface{} `json:"height"`
+	// Relative time override ("1h", "7d") and time shift ("1d") of the
+	// panel's queries. HideTimeOverride hides the override in the header.
+	TimeFrom         string `json:"timeFrom"`
+	TimeShift        string `json:"timeShift"`
+	HideTimeOverride bool   `json:"hideTimeOverride"`
+	// Links are the panel links shown in the panel header menu.
+	Links []GrafanaLink `json:"links"`
+	// LibraryPanel references a library panel instead of embedding the
+	// model. It is cleared once the model has been inlined.
+	LibraryPanel *GrafanaLibraryPanelRef `json:"libraryPanel"`
+	// RowKey identifies a row panel: its position among the dashboard's rows,
+	// from 1. Grafana row ids may be missing or shared, so rows are told
+	// apart by RowKey. It is set by the parser.
+	RowKey int `json:"-"`
+	// RowID is the RowKey of the row panel this panel belongs to, 0 outside
+	// rows. It is set by the parser.
+	RowID int `json:"-"`
+	// Raw holds the panel fields not modelled above, as a JSON object.
+	Raw json.RawMessage `json:"-"`
+}
+
+// GrafanaLink is a dashboard link, panel link or data link. Dashboard links
+// of type "dashboards" list the dashboards carrying Tags instead of a URL.
+type GrafanaLink struct {
+	Title       string   `json:"title"`
+	Type        string   `json:"type"` // link or dashboards (dashboard links)
+	URL         string   `json:"url"`
+	Tags        []string `json:"tags"`
+	TargetBlank bool     `json:"targetBlank"`
+	// KeepTime and IncludeVars append the time range and variables to the URL.
+	KeepTime    bool `json:"keepTime"`
+	IncludeVars bool `json:"includeVars"`
+}
+
+type GrafanaAnnotations struct {
+	List []GrafanaAnnotation `json:"list"`
+}
+
+// GrafanaAnnotation is an annotation query. Prometheus and Loki annotations
+// set Expr; Grafana annotations filter the dashboard's own annotations
+// (Type "dashboard") or all annotations by tags (Type "tags").
+type GrafanaAnnotation struct {
+	Name       string      `json:"name"`
+	Datasource interface{} `json:"datasource"`
+	Enable     bool        `json:"enable"`
+	Hide       bool        `json:"hide"`
+	BuiltIn    int         `json:"builtIn"` // 1 for "Annotations & Alerts"
+	Type       string      `json:"type"`
+	Tags       []string    `json:"tags"`
+	Expr       string      `json:"expr"`
+	Step       string      `json:"step"`
+	// TitleFormat and TextFormat template the event title and text from
+	// the series labels ({{label}}).
+	TitleFormat string `json:"titleFormat"`
+	TextFormat  string `json:"textFormat"`
+	TagKeys     string `json:"tagKeys"`
+	// Target is the query model of newer Grafana annotations.
+	Target *GrafanaAnnotationTarget `json:"target"`
+}
+
+type GrafanaAnnotationTarget struct {
+	Type string   `json:"type"` // dashboard or tags
+	Tags []string `json:"tags"`
+	Expr string   `json:"expr"`
+}
+
+// GrafanaTimeRange is a time range in Grafana's notation, e.g. now-6h to now.
+type GrafanaTimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// GrafanaTimepicker holds the dashboard's time picker settings.
+type GrafanaTimepicker struct {
+	Hidden           bool     `json:"hidden"`
+	RefreshIntervals []string `json:"refresh_intervals"`
+	NowDelay         string   `json:"nowDelay"`
 }
 
 type GrafanaGridPos struct {
@@ -51,14 +196,49 @@ type GrafanaGridPos struct {
 	Y int `json:"y"`
 }
 
+// GrafanaTransformation is a panel data transformation. Options depend on ID.
+type GrafanaTransformation struct {
+	ID       string          `json:"id"`
+	Options  json.RawMessage `json:"options"`
+	Disabled bool            `json:"disabled"`
+}
+
+// GrafanaValueMap is a legacy singlestat value-to-text mapping.
+type GrafanaValueMap struct {
+	Op    string      `json:"op"`
+	Text  string      `json:"text"`
+	Value interface{} `json:"value"`
+}
+
+// GrafanaRangeMap is a legacy singlestat range-to-text mapping.
+type GrafanaRangeMap struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+	Text string      `json:"text"`
+}
+
 type GrafanaTarget struct {
-	RefID        string          `json:"refId"`
-	Expr         string          `json:"expr"`      // PromQL/Expr
-	QueryType    string          `json:"queryType"` // e.g. instant
-	Datasource   interface{}     `json:"datasource"`
-	LegendFormat string          `json:"legendFormat"`
-	Format       string          `json:"format"`
-	Raw          json.RawMessage `json:"-"` // any other fields
+	RefID        string      `json:"refId"`
+	Expr         string      `json:"expr"`      // PromQL/Expr
+	QueryType    string      `json:"queryType"` // e.g. instant
+	Datasource   interface{} `json:"datasource"`
+	LegendFormat string      `json:"legendFormat"`
+	Format       string      `json:"format"`
+	Hide         bool        `json:"hide"`
+	// Prometheus query options.
+	Instant        bool   `json:"instant"`
+	Range          bool   `json:"range"`
+	Interval       string `json:"interval"`
+	IntervalFactor int    `json:"intervalFactor"`
+	Exemplar       bool   `json:"exemplar"`
+	EditorMode     string `json:"editorMode"` // code or builder
+	// Query models of other datasources: SQL, and query strings or metric
+	// lists (e.g. Elasticsearch).
+	RawSQL  string          `json:"rawSql"`
+	Query   interface{}     `json:"query"`
+	Metrics json.RawMessage `json:"metrics"`
+	// Raw holds the target fields not modelled above, as a JSON object.
+	Raw json.RawMessage `json:"-"`
 }
 
 func ParseGrafanaDashboardFile(path string) (*GrafanaDashboard, error) {
@@ -70,25 +250,83 @@ func ParseGrafanaDashboardFile(path string) (*GrafanaDashboard, error) {
 	return ParseGrafanaDashboard(f)
 }
 
+// ParseGrafanaDashboard parses a dashboard model (v1, or the Grafana 12 v2
+// schema), or an API response that wraps one in {"dashboard": ..., "meta": ...}.
 func ParseGrafanaDashboard(r io.Reader) (*GrafanaDashboard, error) {
-	dec := json.NewDecoder(r)
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b, meta, err := unwrapEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+	if isV2(b) {
+		if b, err = v2ToV1(b); err != nil {
+			return nil, err
+		}
+	}
 	var dash GrafanaDashboard
-	if err := dec.Decode(&dash); err != nil {
+	if err := json.Unmarshal(b, &dash); err != nil {
 		return nil, fmt.Errorf("decode grafana json: %w", err)
 	}
+	dash.Meta = meta
+	if len(dash.Rows) > 0 && (dash.SchemaVersion < 16 || len(dash.Panels) == 0) {
+		dash.Panels = migrateRows(dash.Rows)
+		dash.Rows = nil
+	}
 	// Flatten nested row panels, if any.
 	dash.Panels = flattenPanels(dash.Panels)
+	ResolveLibraryPanels(&dash, dash.Elements)
 	return &dash, nil
 }
 
+// flattenPanels returns panels in visual order. Row panels are kept and
+// followed by their members: the nested panels of a collapsed row, or the
+// top-level panels below an expanded row up to the next row. Members carry
+// the row's RowKey in RowID.
 func flattenPanels(panels []GrafanaPanel) []GrafanaPanel {
+	sorted := append([]GrafanaPanel(nil), panels...)
+	SortByGridPos(sorted)
 	out := make([]GrafanaPanel, 0, len(panels))
-	for _, p := range panels {
-		if len(p.Panels) > 0 {
-			out = append(out, flattenPanels(p.Panels)...)
+	row, keys := 0, 0
+	for _, p := range sorted {
+		if p.Type != "row" {
+			p.RowID = row
+			out = append(out, p)
 			continue
 		}
+		keys++
+		p.RowKey, row = keys, keys
+		nested := append([]GrafanaPanel(nil), p.Panels...)
+		SortByGridPos(nested)
+		p.Panels = nil
 		out = append(out, p)
+		for _, c := range nested {
+			c.RowID = row
+			out = append(out, c)
+		}
 	}
 	return out
 }
+
+// SortByGridPos sorts panels top-to-bottom, left-to-right. Panels without a
+// grid position go last, ordered by id.
+func SortByGridPos(panels []GrafanaPanel) {
+	sort.SliceStable(panels, func(i, j int) bool {
+		pi, pj := panels[i].GridPos, panels[j].GridPos
+		if pi == nil && pj == nil {
+			return panels[i].ID < panels[j].ID
+		}
+		if pi == nil {
+			return false
+		}
+		if pj == nil {
+			return true
+		}
+		if pi.Y == pj.Y {
+			return pi.X < pj.X
+		}
+		return pi.Y < pj.Y
+	})
+}
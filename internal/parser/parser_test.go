@@ -2,6 +2,7 @@ package parser
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -24,3 +25,302 @@ func TestParseGrafanaDashboard(t *testing.T) {
 		t.Fatalf("expected 4 panels, got %d", len(dash.Panels))
 	}
 }
+
+func TestParseRows(t *testing.T) {
+	const in = `{
+  "title": "Rows",
+  "panels": [
+    {"id": 10, "type": "row", "title": "Collapsed", "collapsed": true, "gridPos": {"h": 1, "w": 24, "x": 0, "y": 9},
+     "panels": [{"id": 3, "type": "graph", "gridPos": {"h": 8, "w": 12, "x": 0, "y": 10}}]},
+    {"id": 1, "type": "stat", "gridPos": {"h": 4, "w": 6, "x": 0, "y": 0}},
+    {"id": 20, "type": "row", "title": "Expanded", "collapsed": false, "gridPos": {"h": 1, "w": 24, "x": 0, "y": 4}, "panels": []},
+    {"id": 2, "type": "graph", "gridPos": {"h": 4, "w": 6, "x": 0, "y": 5}}
+  ]
+}`
+	dash, err := ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []struct{ id, row int }{{1, 0}, {20, 0}, {2, 1}, {10, 0}, {3, 2}}
+	if len(dash.Panels) != len(want) {
+		t.Fatalf("got %d panels", len(dash.Panels))
+	}
+	for i, w := range want {
+		if p := dash.Panels[i]; p.ID != w.id || p.RowID != w.row {
+			t.Fatalf("panel %d: id=%d row=%d, want id=%d row=%d", i, p.ID, p.RowID, w.id, w.row)
+		}
+	}
+	if !dash.Panels[3].Collapsed {
+		t.Fatalf("row 10 not collapsed")
+	}
+	if dash.Panels[1].RowKey != 1 || dash.Panels[3].RowKey != 2 {
+		t.Fatalf("row keys = %d, %d", dash.Panels[1].RowKey, dash.Panels[3].RowKey)
+	}
+}
+
+func TestResolveLibraryPanels(t *testing.T) {
+	const in = `{
+  "title": "Library",
+  "__elements": {"lib-a": {"uid": "lib-a", "name": "CPU", "kind": 1,
+    "model": {"id": 99, "type": "timeseries", "title": "CPU usage", "gridPos": {"h": 3, "w": 3, "x": 9, "y": 9},
+              "targets": [{"refId": "A", "expr": "rate(cpu[5m])"}]}}},
+  "panels": [
+    {"id": 1, "gridPos": {"h": 8, "w": 12, "x": 0, "y": 0}, "libraryPanel": {"uid": "lib-a", "name": "CPU"}},
+    {"id": 2, "gridPos": {"h": 8, "w": 12, "x": 12, "y": 0}, "libraryPanel": {"uid": "lib-b", "name": "Memory"}},
+    {"id": 3, "gridPos": {"h": 8, "w": 12, "x": 0, "y": 8}, "libraryPanel": {"uid": "gone", "name": "Disk"}}
+  ]
+}`
+	dash, err := ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	p := dash.Panels[0]
+	if p.LibraryPanel != nil || p.Type != "timeseries" || p.Title != "CPU usage" || len(p.Targets) != 1 {
+		t.Fatalf("embedded library panel not inlined: %+v", p)
+	}
+	if p.ID != 1 || p.GridPos.W != 12 || p.GridPos.Y != 0 {
+		t.Fatalf("dashboard id/gridPos not kept: id=%d %+v", p.ID, *p.GridPos)
+	}
+
+	dir := t.TempDir()
+	export := `{"result": {"elements": [{"uid": "other", "name": "Memory", "model": {"type": "stat", "title": ""}}]}}`
+	if err := os.WriteFile(dir+"/lib.json", []byte(export), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lib, err := LoadLibraryPanels(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	missing := ResolveLibraryPanels(dash, lib)
+	if p := dash.Panels[1]; p.Type != "stat" || p.Title != "Memory" {
+		t.Fatalf("library panel not matched by name: %+v", p)
+	}
+	if len(missing) != 1 || missing[0].UID != "gone" || dash.Panels[2].LibraryPanel == nil {
+		t.Fatalf("missing = %+v", missing)
+	}
+
+	// Of elements sharing a name, the lowest uid is used, on every run.
+	lib = LibraryPanels{}
+	for _, uid := range []string{"disk-c", "disk-a", "disk-b"} {
+		lib[uid] = GrafanaLibraryElement{UID: uid, Name: "Disk", Model: []byte(`{"type":"stat","title":"` + uid + `"}`)}
+	}
+	for i := 0; i < 20; i++ {
+		d := &GrafanaDashboard{Panels: []GrafanaPanel{{ID: 3, LibraryPanel: &GrafanaLibraryPanelRef{UID: "gone", Name: "Disk"}}}}
+		if missing := ResolveLibraryPanels(d, lib); len(missing) != 0 || d.Panels[0].Title != "disk-a" {
+			t.Fatalf("run %d: title = %q, missing = %+v", i, d.Panels[0].Title, missing)
+		}
+	}
+}
+
+func TestMigrateLegacyRows(t *testing.T) {
+	const in = `{
+  "title": "Legacy",
+  "schemaVersion": 14,
+  "rows": [
+    {"title": "Overview", "showTitle": true, "height": "250px", "panels": [
+      {"id": 1, "type": "singlestat", "span": 4},
+      {"id": 2, "type": "graph", "span": 8},
+      {"id": 3, "type": "graph", "span": 6, "height": 400}
+    ]},
+    {"title": "Details", "collapse": true, "height": 200, "panels": [
+      {"id": 4, "type": "table", "span": 12}
+    ]},
+    {"title": "More", "panels": [{"id": 5, "type": "graph"}]}
+  ]
+}`
+	dash, err := ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []struct {
+		id, row    int
+		typ        string
+		x, y, w, h int
+	}{
+		{6, 0, "row", 0, 0, 24, 1},
+		{1, 1, "singlestat", 0, 1, 8, 7},
+		{2, 1, "graph", 8, 1, 16, 7},
+		{3, 1, "graph", 0, 8, 12, 11},
+		{7, 0, "row", 0, 19, 24, 1},
+		{4, 2, "table", 0, 20, 24, 6},
+		{8, 0, "row", 0, 20, 24, 1},
+		{5, 3, "graph", 0, 21, 8, 7},
+	}
+	if len(dash.Panels) != len(want) {
+		t.Fatalf("got %d panels", len(dash.Panels))
+	}
+	for i, w := range want {
+		p := dash.Panels[i]
+		g := p.GridPos
+		if p.ID != w.id || p.RowID != w.row || p.Type != w.typ || g.X != w.x || g.Y != w.y || g.W != w.w || g.H != w.h {
+			t.Fatalf("panel %d: id=%d row=%d %s %+v, want %+v", i, p.ID, p.RowID, p.Type, *g, w)
+		}
+	}
+	if !dash.Panels[4].Collapsed || dash.Rows != nil {
+		t.Fatalf("collapsed=%v rows=%v", dash.Panels[4].Collapsed, dash.Rows)
+	}
+}
+
+func TestParseAPIEnvelope(t *testing.T) {
+	const in = `{
+  "meta": {"folderUid": "f1", "folderTitle": "Platform", "url": "/d/abc/api", "version": 7},
+  "dashboard": {
+    "uid": "abc", "title": "API", "tags": ["k8s"],
+    "__inputs": [{"name": "DS_PROMETHEUS", "type": "datasource", "pluginId": "prometheus"}],
+    "__requires": [{"type": "panel", "id": "timeseries", "name": "Time series"}],
+    "panels": [{"id": 1, "type": "timeseries"}]
+  }
+}`
+	dash, err := ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if dash.Title != "API" || len(dash.Panels) != 1 || len(dash.Tags) != 1 {
+		t.Fatalf("dashboard not unwrapped: %+v", dash)
+	}
+	if dash.Meta == nil || dash.Meta.FolderTitle != "Platform" {
+		t.Fatalf("meta = %+v", dash.Meta)
+	}
+	if len(dash.Inputs) != 1 || dash.Inputs[0].PluginID != "prometheus" || len(dash.Requires) != 1 {
+		t.Fatalf("inputs = %+v, requires = %+v", dash.Inputs, dash.Requires)
+	}
+}
+
+func TestParseV2Dashboard(t *testing.T) {
+	const in = `{
+  "apiVersion": "dashboard.grafana.app/v2beta1",
+  "kind": "Dashboard",
+  "metadata": {"name": "v2-uid"},
+  "spec": {
+    "title": "V2",
+    "elements": {
+      "panel-1": {"kind": "Panel", "spec": {"id": 1, "title": "Up",
+        "data": {"kind": "QueryGroup", "spec": {"queries": [
+          {"kind": "PanelQuery", "spec": {"refId": "A", "hidden": true,
+            "query": {"kind": "DataQuery", "group": "prometheus", "spec": {"expr": "up", "legendFormat": "{{job}}"}},
+            "datasource": {"uid": "${DS_PROMETHEUS}"}}}
+        ], "transformations": [{"kind": "organize", "spec": {"id": "organize", "options": {}}}]}},
+        "vizConfig": {"kind": "VizConfig", "group": "stat", "spec": {"options": {"reduceOptions": {"calcs": ["last"]}}}}}},
+      "panel-2": {"kind": "Panel", "spec": {"id": 2, "title": "Errors",
+        "vizConfig": {"kind": "timeseries", "spec": {}}}},
+      "panel-3": {"kind": "LibraryPanel", "spec": {"id": 3, "title": "Lib", "libraryPanel": {"uid": "lib", "name": "Lib"}}}
+    },
+    "layout": {"kind": "TabsLayout", "spec": {"tabs": [
+      {"kind": "TabsLayoutTab", "spec": {"title": "Overview", "layout": {"kind": "GridLayout", "spec": {"items": [
+        {"kind": "GridLayoutItem", "spec": {"x": 0, "y": 0, "width": 12, "height": 6, "element": {"kind": "ElementReference", "name": "panel-1"}}},
+        {"kind": "GridLayoutItem", "spec": {"x": 12, "y": 0, "width": 12, "height": 6, "element": {"kind": "ElementReference", "name": "panel-2"},
+          "repeat": {"mode": "variable", "value": "job", "direction": "h"}}}
+      ]}}}},
+      {"kind": "TabsLayoutTab", "spec": {"title": "Library", "layout": {"kind": "AutoGridLayout", "spec": {"maxColumnCount": 2, "rowHeightMode": "short", "items": [
+        {"kind": "AutoGridLayoutItem", "spec": {"element": {"kind": "ElementReference", "name": "panel-3"}}}
+      ]}}}}
+    ]}},
+    "variables": [
+      {"kind": "QueryVariable", "spec": {"name": "job", "definition": "label_values(up, job)", "sort": "alphabeticalDesc", "multi": true,
+        "query": {"kind": "DataQuery", "group": "prometheus", "spec": {"query": "label_values(up, job)"}}}},
+      {"kind": "DatasourceVariable", "spec": {"name": "ds", "pluginId": "prometheus"}}
+    ]
+  }
+}`
+	dash, err := ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if dash.UID != "v2-uid" || dash.Title != "V2" {
+		t.Fatalf("uid=%q title=%q", dash.UID, dash.Title)
+	}
+	want := []struct {
+		id, row int
+		typ     string
+		y, w    int
+	}{{4, 0, "row", 0, 24}, {1, 1, "stat", 1, 12}, {2, 1, "timeseries", 1, 12}, {5, 0, "row", 7, 24}, {3, 2, "", 8, 12}}
+	if len(dash.Panels) != len(want) {
+		t.Fatalf("got %d panels", len(dash.Panels))
+	}
+	for i, w := range want {
+		p := dash.Panels[i]
+		if p.ID != w.id || p.RowID != w.row || p.Type != w.typ || p.GridPos.Y != w.y || p.GridPos.W != w.w {
+			t.Fatalf("panel %d: id=%d row=%d %q %+v, want %+v", i, p.ID, p.RowID, p.Type, *p.GridPos, w)
+		}
+	}
+	up := dash.Panels[1]
+	if len(up.Targets) != 1 || up.Targets[0].Expr != "up" || up.Targets[0].LegendFormat != "{{job}}" || len(up.Transformations) != 1 {
+		t.Fatalf("panel 1 = %+v", up)
+	}
+	if ds, ok := up.Targets[0].Datasource.(map[string]interface{}); !ok || ds["type"] != "prometheus" {
+		t.Fatalf("datasource = %v", up.Targets[0].Datasource)
+	}
+	if dash.Panels[2].Repeat != "job" || dash.Panels[4].LibraryPanel == nil || dash.Panels[4].GridPos.H != 5 {
+		t.Fatalf("repeat/library panel not kept: %+v %+v", dash.Panels[2], dash.Panels[4])
+	}
+	vars := dash.Templating.List
+	if len(vars) != 2 || vars[0].Type != "query" || vars[0].Query != "label_values(up, job)" || !vars[0].Multi || vars[1].Type != "datasource" || vars[1].Query != "prometheus" {
+		t.Fatalf("variables = %+v", vars)
+	}
+}
+
+func TestParseV2RowsLayout(t *testing.T) {
+	const in = `{
+  "apiVersion": "dashboard.grafana.app/v2beta1",
+  "kind": "Dashboard",
+  "metadata": {"name": "rows"},
+  "spec": {
+    "title": "Rows",
+    "elements": {
+      "panel-1": {"kind": "Panel", "spec": {"id": 1, "title": "A", "vizConfig": {"kind": "VizConfig", "group": "timeseries", "spec": {}}}},
+      "panel-2": {"kind": "Panel", "spec": {"id": 2, "title": "B", "vizConfig": {"kind": "VizConfig", "group": "stat", "spec": {}}}}
+    },
+    "layout": {"kind": "RowsLayout", "spec": {"rows": [
+      {"kind": "RowsLayoutRow", "spec": {"title": "First", "layout": {"kind": "GridLayout", "spec": {"items": [
+        {"kind": "GridLayoutItem", "spec": {"x": 0, "y": 0, "width": 24, "height": 8, "element": {"kind": "ElementReference", "name": "panel-1"}}}
+      ]}}}},
+      {"kind": "RowsLayoutRow", "spec": {"title": "Second", "collapse": true, "layout": {"kind": "AutoGridLayout",
+        "spec": {"maxColumnCount": 3, "rowHeightMode": "custom", "rowHeight": 380, "items": [
+          {"kind": "AutoGridLayoutItem", "spec": {"element": {"kind": "ElementReference", "name": "panel-2"}}}
+        ]}}}}
+    ]}}
+  }
+}`
+	dash, err := ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(dash.Panels) != 4 {
+		t.Fatalf("got %d panels", len(dash.Panels))
+	}
+	first, a, second, b := dash.Panels[0], dash.Panels[1], dash.Panels[2], dash.Panels[3]
+	if first.Type != "row" || first.Title != "First" || a.ID != 1 || a.RowID != first.RowKey || a.GridPos.Y != 1 {
+		t.Fatalf("first row = %+v / %+v", first, a)
+	}
+	if second.Type != "row" || !second.Collapsed || second.GridPos.Y != 9 || b.ID != 2 || b.RowID != second.RowKey {
+		t.Fatalf("second row = %+v / %+v", second, b)
+	}
+	if b.GridPos.W != 8 || b.GridPos.H != 10 {
+		t.Fatalf("auto grid item = %+v", *b.GridPos)
+	}
+}
+
+func TestRawFields(t *testing.T) {
+	const in = `{"panels": [{"id": 1, "type": "timeseries", "pluginVersion": "10.0.0", "maxDataPoints": 100,
+  "targets": [{"refId": "A", "expr": "up", "hide": true, "instant": true, "range": false, "interval": "1m",
+               "intervalFactor": 2, "exemplar": true, "editorMode": "code", "step": 30, "hinting": {"x": 1}},
+              {"refId": "B", "rawSql": "SELECT 1", "query": "status:500", "metrics": [{"type": "count", "id": "1"}]}]}]}`
+	dash, err := ParseGrafanaDashboard(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	p := dash.Panels[0]
+	if got := string(p.Raw); got != `{"maxDataPoints":100,"pluginVersion":"10.0.0"}` {
+		t.Fatalf("panel raw = %s", got)
+	}
+	a, b := p.Targets[0], p.Targets[1]
+	if !a.Hide || !a.Instant || a.Range || a.Interval != "1m" || a.IntervalFactor != 2 || !a.Exemplar || a.EditorMode != "code" {
+		t.Fatalf("target A = %+v", a)
+	}
+	if got := string(a.Raw); got != `{"hinting":{"x":1},"step":30}` {
+		t.Fatalf("target A raw = %s", got)
+	}
+	if b.RawSQL != "SELECT 1" || b.Query != "status:500" || len(b.Metrics) == 0 || b.Raw != nil {
+		t.Fatalf("target B = %+v", b)
+	}
+}
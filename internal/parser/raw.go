@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// UnmarshalJSON decodes a target and keeps the fields the struct does not
+// model in Raw.
+func (t *GrafanaTarget) UnmarshalJSON(b []byte) error {
+	type plain GrafanaTarget
+	if err := json.Unmarshal(b, (*plain)(t)); err != nil {
+		return err
+	}
+	raw, err := extraFields(b, targetFields)
+	t.Raw = raw
+	return err
+}
+
+// UnmarshalJSON decodes a panel and keeps the fields the struct does not
+// model in Raw.
+func (p *GrafanaPanel) UnmarshalJSON(b []byte) error {
+	type plain GrafanaPanel
+	if err := json.Unmarshal(b, (*plain)(p)); err != nil {
+		return err
+	}
+	raw, err := extraFields(b, panelFields)
+	p.Raw = raw
+	return err
+}
+
+var (
+	targetFields = jsonFields(reflect.TypeOf(GrafanaTarget{}))
+	panelFields  = jsonFields(reflect.TypeOf(GrafanaPanel{}))
+)
+
+// jsonFields returns the lower-cased JSON names of a struct's fields, the
+// way encoding/json matches object keys.
+func jsonFields(t reflect.Type) map[string]bool {
+	out := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out[strings.ToLower(name)] = true
+	}
+	return out
+}
+
+// extraFields returns the members of the JSON object b whose keys are not
+// in known, re-encoded with sorted keys, or nil when there are none.
+func extraFields(b []byte, known map[string]bool) (json.RawMessage, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k := range m {
+		if known[strings.ToLower(k)] {
+			delete(m, k)
+		}
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
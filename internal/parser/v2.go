@@ -0,0 +1,565 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Grafana 12 stores dashboards in the v2 schema: panels are "elements"
+// referenced by name from a "layout" (grid, rows, tabs or auto grid), and
+// variables are kinds. The parser normalises v2 into the v1 model so that
+// everything downstream sees one shape; tabs become rows.
+
+type v2Kind struct {
+	Kind string          `json:"kind"`
+	Spec json.RawMessage `json:"spec"`
+	// Group names the plugin of DataQuery/VizConfig kinds (v2beta1).
+	Group string `json:"group"`
+}
+
+type v2Resource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+type v2Spec struct {
+	Title        string                     `json:"title"`
+	Description  string                     `json:"description"`
+	Tags         []string                   `json:"tags"`
+	Links        json.RawMessage            `json:"links"`
+	Elements     map[string]v2Kind          `json:"elements"`
+	Layout       v2Kind                     `json:"layout"`
+	Variables    []v2Kind                   `json:"variables"`
+	Annotations  []v2Kind                   `json:"annotations"`
+	TimeSettings map[string]json.RawMessage `json:"timeSettings"`
+}
+
+type v2Panel struct {
+	ID           int             `json:"id"`
+	Title        string          `json:"title"`
+	Description  string          `json:"description"`
+	Links        json.RawMessage `json:"links"`
+	Transparent  bool            `json:"transparent"`
+	LibraryPanel json.RawMessage `json:"libraryPanel"`
+	Data         v2Kind          `json:"data"`
+	VizConfig    v2Kind          `json:"vizConfig"`
+}
+
+type v2QueryGroup struct {
+	Queries         []v2Kind                   `json:"queries"`
+	Transformations []v2Kind                   `json:"transformations"`
+	QueryOptions    map[string]json.RawMessage `json:"queryOptions"`
+}
+
+type v2PanelQuery struct {
+	RefID      string          `json:"refId"`
+	Hidden     bool            `json:"hidden"`
+	Query      v2Kind          `json:"query"`
+	Datasource json.RawMessage `json:"datasource"`
+}
+
+type v2Repeat struct {
+	Value     string `json:"value"`
+	Direction string `json:"direction"`
+	MaxPerRow int    `json:"maxPerRow"`
+}
+
+type v2GridItem struct {
+	X       int       `json:"x"`
+	Y       int       `json:"y"`
+	Width   int       `json:"width"`
+	Height  int       `json:"height"`
+	Element v2Element `json:"element"`
+	Repeat  *v2Repeat `json:"repeat"`
+	// GridLayoutRow (v2alpha1) fields.
+	Title     string   `json:"title"`
+	Collapsed bool     `json:"collapsed"`
+	Elements  []v2Kind `json:"elements"`
+}
+
+type v2Element struct {
+	Name string `json:"name"`
+}
+
+type v2Section struct {
+	Title    string    `json:"title"`
+	Collapse bool      `json:"collapse"`
+	Repeat   *v2Repeat `json:"repeat"`
+	Layout   v2Kind    `json:"layout"`
+}
+
+type v2AutoGrid struct {
+	MaxColumnCount float64 `json:"maxColumnCount"`
+	// RowHeightMode is short, standard, tall or custom; custom heights are
+	// in RowHeight (pixels).
+	RowHeightMode string   `json:"rowHeightMode"`
+	RowHeight     float64  `json:"rowHeight"`
+	Items         []v2Kind `json:"items"`
+}
+
+// isV2 reports whether b is a v2 dashboard resource or a bare v2 spec.
+func isV2(b []byte) bool {
+	var probe struct {
+		APIVersion string          `json:"apiVersion"`
+		Spec       json.RawMessage `json:"spec"`
+		Elements   json.RawMessage `json:"elements"`
+		Layout     json.RawMessage `json:"layout"`
+	}
+	if json.Unmarshal(b, &probe) != nil {
+		return false
+	}
+	if strings.HasPrefix(probe.APIVersion, "dashboard.grafana.app/v2") {
+		return true
+	}
+	return len(probe.Elements) > 0 && len(probe.Layout) > 0
+}
+
+// v2ToV1 converts a v2 dashboard into v1 dashboard JSON.
+func v2ToV1(b []byte) ([]byte, error) {
+	var res v2Resource
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("decode v2 dashboard: %w", err)
+	}
+	raw := res.Spec
+	if res.APIVersion == "" {
+		raw = b
+	}
+	var spec v2Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("decode v2 dashboard spec: %w", err)
+	}
+
+	c := v2Converter{elements: spec.Elements}
+	for _, el := range spec.Elements {
+		var p v2Panel
+		if json.Unmarshal(el.Spec, &p) == nil && p.ID >= c.nextID {
+			c.nextID = p.ID + 1
+		}
+	}
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	c.layout(spec.Layout, 0, nil)
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	dash := map[string]interface{}{
+		"uid":           res.Metadata.Name,
+		"title":         spec.Title,
+		"description":   spec.Description,
+		"tags":          spec.Tags,
+		"schemaVersion": 41,
+		"panels":        c.panels,
+		"templating":    map[string]interface{}{"list": v2Variables(spec.Variables)},
+		"annotations":   map[string]interface{}{"list": v2Annotations(spec.Annotations)},
+	}
+	if len(spec.Links) > 0 {
+		dash["links"] = spec.Links
+	}
+	for k, v := range v2TimeSettings(spec.TimeSettings) {
+		dash[k] = v
+	}
+	return json.Marshal(dash)
+}
+
+type v2Converter struct {
+	elements map[string]v2Kind
+	panels   []map[string]interface{}
+	nextID   int
+	err      error
+}
+
+// layout appends the panels of a layout at y offset y0. Panels inside a
+// collapsed row are nested in row["panels"]. It returns the height used.
+func (c *v2Converter) layout(l v2Kind, y0 int, row map[string]interface{}) int {
+	switch l.Kind {
+	case "GridLayout":
+		var g struct {
+			Items []v2Kind `json:"items"`
+		}
+		if err := json.Unmarshal(l.Spec, &g); err != nil {
+			c.err = fmt.Errorf("decode GridLayout: %w", err)
+			return 0
+		}
+		return c.gridItems(g.Items, y0, row)
+	case "RowsLayout", "TabsLayout":
+		// Sections are in rows (RowsLayout) or tabs (TabsLayout); items is
+		// accepted from pre-release schemas.
+		var s struct {
+			Rows  []v2Kind `json:"rows"`
+			Tabs  []v2Kind `json:"tabs"`
+			Items []v2Kind `json:"items"`
+		}
+		if err := json.Unmarshal(l.Spec, &s); err != nil {
+			c.err = fmt.Errorf("decode %s: %w", l.Kind, err)
+			return 0
+		}
+		sections := s.Rows
+		if l.Kind == "TabsLayout" {
+			sections = s.Tabs
+		}
+		if len(sections) == 0 {
+			sections = s.Items
+		}
+		y := y0
+		for _, it := range sections {
+			var sec v2Section
+			if err := json.Unmarshal(it.Spec, &sec); err != nil {
+				c.err = fmt.Errorf("decode %s: %w", it.Kind, err)
+				return 0
+			}
+			// Tabs show one at a time; as rows they are all expanded.
+			r := c.row(sec.Title, sec.Collapse && it.Kind != "TabsLayoutTab", sec.Repeat, y)
+			h := c.layout(sec.Layout, y+1, r)
+			y++
+			if !sec.Collapse || it.Kind == "TabsLayoutTab" {
+				y += h
+			}
+		}
+		return y - y0
+	case "AutoGridLayout":
+		var a v2AutoGrid
+		if err := json.Unmarshal(l.Spec, &a); err != nil {
+			c.err = fmt.Errorf("decode AutoGridLayout: %w", err)
+			return 0
+		}
+		cols := int(a.MaxColumnCount)
+		if cols <= 0 {
+			cols = 3
+		}
+		w, h := 24/cols, autoGridHeight(a.RowHeightMode, a.RowHeight)
+		for i, it := range a.Items {
+			var item v2GridItem
+			if err := json.Unmarshal(it.Spec, &item); err != nil {
+				c.err = fmt.Errorf("decode AutoGridLayoutItem: %w", err)
+				return 0
+			}
+			item.X, item.Y, item.Width, item.Height = (i%cols)*w, (i/cols)*h, w, h
+			c.panel(item, y0, row)
+		}
+		return (len(a.Items) + cols - 1) / cols * h
+	case "":
+		return 0
+	}
+	c.err = fmt.Errorf("unsupported v2 layout kind %q", l.Kind)
+	return 0
+}
+
+func (c *v2Converter) gridItems(items []v2Kind, y0 int, row map[string]interface{}) int {
+	bottom := 0
+	for _, it := range items {
+		var item v2GridItem
+		if err := json.Unmarshal(it.Spec, &item); err != nil {
+			c.err = fmt.Errorf("decode %s: %w", it.Kind, err)
+			return 0
+		}
+		if it.Kind == "GridLayoutRow" {
+			// Row children are positioned relative to the row.
+			r := c.row(item.Title, item.Collapsed, item.Repeat, y0+item.Y)
+			h := c.gridItems(item.Elements, y0+item.Y+1, r)
+			end := item.Y + 1
+			if !item.Collapsed {
+				end += h
+			}
+			if end > bottom {
+				bottom = end
+			}
+			continue
+		}
+		c.panel(item, y0, row)
+		if end := item.Y + item.Height; end > bottom {
+			bottom = end
+		}
+	}
+	return bottom
+}
+
+// row appends a v1 row panel at y and returns it.
+func (c *v2Converter) row(title string, collapsed bool, rep *v2Repeat, y int) map[string]interface{} {
+	r := map[string]interface{}{
+		"id":        c.nextID,
+		"type":      "row",
+		"title":     title,
+		"collapsed": collapsed,
+		"gridPos":   map[string]int{"x": 0, "y": y, "w": 24, "h": 1},
+		"panels":    []map[string]interface{}{},
+	}
+	if rep != nil {
+		r["repeat"] = rep.Value
+	}
+	c.nextID++
+	c.panels = append(c.panels, r)
+	return r
+}
+
+// panel converts the element an item references and places it at the
+// item's position offset by y0.
+func (c *v2Converter) panel(item v2GridItem, y0 int, row map[string]interface{}) {
+	el, ok := c.elements[item.Element.Name]
+	if !ok {
+		c.err = fmt.Errorf("layout references unknown element %q", item.Element.Name)
+		return
+	}
+	p, err := v2PanelToV1(el)
+	if err != nil {
+		c.err = fmt.Errorf("element %q: %w", item.Element.Name, err)
+		return
+	}
+	p["gridPos"] = map[string]int{"x": item.X, "y": y0 + item.Y, "w": item.Width, "h": item.Height}
+	if rep := item.Repeat; rep != nil {
+		p["repeat"] = rep.Value
+		p["repeatDirection"] = rep.Direction
+		p["maxPerRow"] = rep.MaxPerRow
+	}
+	if row != nil && row["collapsed"] == true {
+		row["panels"] = append(row["panels"].([]map[string]interface{}), p)
+		return
+	}
+	c.panels = append(c.panels, p)
+}
+
+// v2PanelToV1 converts a Panel or LibraryPanel element into a v1 panel.
+func v2PanelToV1(el v2Kind) (map[string]interface{}, error) {
+	var p v2Panel
+	if err := json.Unmarshal(el.Spec, &p); err != nil {
+		return nil, err
+	}
+	out := map[string]interface{}{
+		"id":          p.ID,
+		"title":       p.Title,
+		"description": p.Description,
+		"transparent": p.Transparent,
+	}
+	if len(p.Links) > 0 {
+		out["links"] = p.Links
+	}
+	if el.Kind == "LibraryPanel" {
+		out["libraryPanel"] = p.LibraryPanel
+		return out, nil
+	}
+
+	out["type"] = nonEmptyTitle(p.VizConfig.Group, p.VizConfig.Kind)
+	var viz struct {
+		Options     json.RawMessage `json:"options"`
+		FieldConfig json.RawMessage `json:"fieldConfig"`
+	}
+	if len(p.VizConfig.Spec) > 0 {
+		if err := json.Unmarshal(p.VizConfig.Spec, &viz); err != nil {
+			return nil, fmt.Errorf("decode vizConfig: %w", err)
+		}
+	}
+	if len(viz.Options) > 0 {
+		out["options"] = viz.Options
+	}
+	if len(viz.FieldConfig) > 0 {
+		out["fieldConfig"] = viz.FieldConfig
+	}
+
+	var qg v2QueryGroup
+	if len(p.Data.Spec) > 0 {
+		if err := json.Unmarshal(p.Data.Spec, &qg); err != nil {
+			return nil, fmt.Errorf("decode queries: %w", err)
+		}
+	}
+	targets := make([]map[string]interface{}, 0, len(qg.Queries))
+	for _, q := range qg.Queries {
+		var pq v2PanelQuery
+		if err := json.Unmarshal(q.Spec, &pq); err != nil {
+			return nil, fmt.Errorf("decode query: %w", err)
+		}
+		t := map[string]interface{}{}
+		if len(pq.Query.Spec) > 0 {
+			if err := json.Unmarshal(pq.Query.Spec, &t); err != nil {
+				return nil, fmt.Errorf("decode query %s: %w", pq.RefID, err)
+			}
+		}
+		t["refId"] = pq.RefID
+		if pq.Hidden {
+			t["hide"] = true
+		}
+		// v2alpha1 names the plugin in the query kind, v2beta1 in its group.
+		dsType := pq.Query.Group
+		if dsType == "" && pq.Query.Kind != "DataQuery" {
+			dsType = pq.Query.Kind
+		}
+		ds := map[string]interface{}{}
+		if len(pq.Datasource) > 0 {
+			_ = json.Unmarshal(pq.Datasource, &ds)
+		}
+		if dsType != "" {
+			ds["type"] = dsType
+		}
+		if len(ds) > 0 {
+			t["datasource"] = ds
+		}
+		targets = append(targets, t)
+	}
+	out["targets"] = targets
+
+	var trs []map[string]interface{}
+	for _, tr := range qg.Transformations {
+		t := map[string]interface{}{}
+		if err := json.Unmarshal(tr.Spec, &t); err != nil {
+			return nil, fmt.Errorf("decode transformation: %w", err)
+		}
+		if _, ok := t["id"]; !ok {
+			t["id"] = tr.Kind
+		}
+		trs = append(trs, t)
+	}
+	if len(trs) > 0 {
+		out["transformations"] = trs
+	}
+	// Query options (interval, timeFrom, timeShift, ...) are panel fields in v1.
+	for k, v := range qg.QueryOptions {
+		out[k] = v
+	}
+	return out, nil
+}
+
+// v2VariableTypes maps v2 variable kinds onto v1 variable types.
+var v2VariableTypes = map[string]string{
+	"QueryVariable":      "query",
+	"CustomVariable":     "custom",
+	"DatasourceVariable": "datasource",
+	"ConstantVariable":   "constant",
+	"TextVariable":       "textbox",
+	"IntervalVariable":   "interval",
+	"AdhocVariable":      "adhoc",
+	"GroupByVariable":    "groupby",
+}
+
+// v1 numeric codes of the v2 enum strings.
+var (
+	v2Sort = map[string]int{
+		"disabled": 0, "alphabeticalAsc": 1, "alphabeticalDesc": 2, "numericalAsc": 3, "numericalDesc": 4,
+		"alphabeticalCaseInsensitiveAsc": 5, "alphabeticalCaseInsensitiveDesc": 6, "naturalAsc": 7, "naturalDesc": 8,
+	}
+	v2Hide    = map[string]int{"dontHide": 0, "hideLabel": 1, "hideVariable": 2}
+	v2Refresh = map[string]int{"never": 0, "onDashboardLoad": 1, "onTimeRangeChanged": 2}
+)
+
+func v2Variables(vars []v2Kind) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(vars))
+	for _, v := range vars {
+		m := map[string]interface{}{}
+		if json.Unmarshal(v.Spec, &m) != nil {
+			continue
+		}
+		m["type"] = nonEmptyTitle(v2VariableTypes[v.Kind], strings.ToLower(strings.TrimSuffix(v.Kind, "Variable")))
+		for key, codes := range map[string]map[string]int{"sort": v2Sort, "hide": v2Hide, "refresh": v2Refresh} {
+			if s, ok := m[key].(string); ok {
+				m[key] = codes[s]
+			}
+		}
+		switch v.Kind {
+		case "QueryVariable":
+			// The definition is the query as typed; the query kind wraps the
+			// datasource-specific model.
+			if def, ok := m["definition"].(string); ok && def != "" {
+				m["query"] = def
+			} else if q, ok := m["query"].(map[string]interface{}); ok {
+				spec, _ := q["spec"].(map[string]interface{})
+				for _, k := range []string{"query", "expr"} {
+					if s, ok := spec[k].(string); ok && s != "" {
+						m["query"] = s
+						break
+					}
+				}
+			}
+		case "DatasourceVariable":
+			m["query"] = m["pluginId"]
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func v2Annotations(anns []v2Kind) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(anns))
+	for _, a := range anns {
+		m := map[string]interface{}{}
+		if json.Unmarshal(a.Spec, &m) != nil {
+			continue
+		}
+		// v1 marks the built-in annotation with builtIn: 1.
+		if b, ok := m["builtIn"].(bool); ok {
+			delete(m, "builtIn")
+			if b {
+				m["builtIn"] = 1
+			}
+		}
+		// The query kind wraps the datasource-specific model.
+		if q, ok := m["query"].(map[string]interface{}); ok {
+			delete(m, "query")
+			if spec, ok := q["spec"].(map[string]interface{}); ok {
+				for k, v := range spec {
+					if _, set := m[k]; !set {
+						m[k] = v
+					}
+				}
+			}
+			ds, _ := m["datasource"].(map[string]interface{})
+			if ds == nil {
+				ds = map[string]interface{}{}
+			}
+			if g, ok := q["group"].(string); ok && g != "" {
+				ds["type"] = g
+			} else if k, ok := q["kind"].(string); ok && k != "DataQuery" {
+				ds["type"] = k
+			}
+			m["datasource"] = ds
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// v2TimeSettings maps timeSettings onto the v1 time, refresh, timezone and
+// timepicker fields.
+func v2TimeSettings(ts map[string]json.RawMessage) map[string]interface{} {
+	out := map[string]interface{}{}
+	if len(ts) == 0 {
+		return out
+	}
+	str := func(k string) string {
+		var s string
+		_ = json.Unmarshal(ts[k], &s)
+		return s
+	}
+	out["time"] = map[string]string{"from": str("from"), "to": str("to")}
+	out["refresh"] = str("autoRefresh")
+	out["timezone"] = str("timezone")
+	picker := map[string]interface{}{}
+	if raw, ok := ts["autoRefreshIntervals"]; ok {
+		picker["refresh_intervals"] = raw
+	}
+	if raw, ok := ts["hideTimepicker"]; ok {
+		picker["hidden"] = raw
+	}
+	out["timepicker"] = picker
+	return out
+}
+
+// autoGridHeight converts an AutoGridLayout row height (a preset mode, or
+// custom pixels) into grid units.
+func autoGridHeight(mode string, px float64) int {
+	switch mode {
+	case "short":
+		return 5
+	case "tall":
+		return 14
+	case "custom", "":
+		if px > 0 {
+			return gridHeight(px)
+		}
+	}
+	return 9
+}